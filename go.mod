@@ -0,0 +1,3 @@
+module github.com/ribacq/LaGrueCendree
+
+go 1.16
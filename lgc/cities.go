@@ -0,0 +1,233 @@
+package lgc
+
+import (
+	"context"
+	"math"
+)
+
+// city placement tuning, the suitability of a square being the product of
+// its water, fertility and lowland factors
+const (
+	// bonus for fresh water, a river or a lake, next to the square
+	CITY_FRESH_WATER float64 = 3
+	// bonus for the sea next to the square, and more for a shallow anchorage
+	CITY_COAST   float64 = 2
+	CITY_HARBOUR float64 = 1
+	// share of the suitability kept on the highest ground
+	CITY_HIGHLAND float64 = .3
+)
+
+// CitySuitability scores how well a city would do on a square, 0 where none
+// can be founded, as on the map borders, without regard to the other cities
+func (grid *Grid) CitySuitability(y, x int) float64 {
+	st := grid.Squares[y][x]
+	if grid.OnBorder(y, x) || st.Terrain != TERRAIN_LAND || st.Feature != FEATURE_NONE || BIOME_HABITABILITY[st.Biome] == 0 {
+		return 0
+	}
+	var freshWater, coast, harbour bool
+	for _, dir := range DIR_SQUARE {
+		nhbY, nhbX, ok := grid.Neighbour(y, x, dir)
+		if !ok {
+			continue
+		}
+		nhb := grid.Squares[nhbY][nhbX]
+		switch {
+		case nhb.Feature == FEATURE_RIVER, nhb.Terrain == TERRAIN_LAKE && !nhb.IsFrozen():
+			freshWater = true
+		case nhb.Terrain == TERRAIN_SEA && !nhb.IsFrozen():
+			coast = true
+			harbour = harbour || nhb.IsShallow()
+		}
+	}
+	water := 1.0
+	if freshWater {
+		water += CITY_FRESH_WATER
+	}
+	if coast {
+		water += CITY_COAST
+	}
+	if harbour {
+		water += CITY_HARBOUR
+	}
+	// low Val is high ground
+	lowland := CITY_HIGHLAND + (1-CITY_HIGHLAND)*float64(st.Val)/255
+	return water * float64(BIOME_HABITABILITY[st.Biome]) / 100 * lowland
+}
+
+// citySpacing is how close cities may be founded, in squares
+func (grid *Grid) citySpacing() int {
+	return grid.Magic() / 6
+}
+
+// city tiers, from the smallest
+const (
+	CITY_TIER_HAMLET = iota
+	CITY_TIER_TOWN
+	CITY_TIER_CITY
+	CITY_TIER_CAPITAL
+)
+
+var CITY_TIER_NAMES = [...]string{
+	CITY_TIER_HAMLET:  "hamlet",
+	CITY_TIER_TOWN:    "town",
+	CITY_TIER_CITY:    "city",
+	CITY_TIER_CAPITAL: "capital",
+}
+
+// city population tuning
+const (
+	// people a city starts with for each point of suitability of its spot
+	CITY_POP_PER_SUITABILITY float64 = 1500
+	// the cities founding a country start that many times bigger
+	CITY_CAPITAL_FACTOR = 3
+	// populations from which a hamlet is a town, and a town a city
+	CITY_TOWN_POP = 1000
+	CITY_CITY_POP = 5000
+	// growth in percent a turn of a city on the best spot, half of it on the worst
+	CITY_GROWTH_PCT float64 = 8
+)
+
+// squares covered by a city of each tier, no more than by one of the tier
+// above; conurbations keep the squares of the cities they took in
+var CITY_TIER_SQUARES = [...]int{
+	CITY_TIER_HAMLET:  1,
+	CITY_TIER_TOWN:    2,
+	CITY_TIER_CITY:    4,
+	CITY_TIER_CAPITAL: 6,
+}
+
+func (c *City) TierName() string {
+	return CITY_TIER_NAMES[c.Tier]
+}
+
+// setTier follows the population, capitals stay capitals
+func (c *City) setTier() {
+	switch {
+	case c.Tier == CITY_TIER_CAPITAL:
+	case c.Population >= CITY_CITY_POP:
+		c.Tier = CITY_TIER_CITY
+	case c.Population >= CITY_TOWN_POP:
+		c.Tier = CITY_TIER_TOWN
+	default:
+		c.Tier = CITY_TIER_HAMLET
+	}
+}
+
+// spreadCity builds on the free land next to city ic until it covers the
+// squares of its tier, along rivers and coasts first and close to its centre;
+// with merge, it may run into another city, the smaller one then joins the
+// other, and the index of the city left is returned
+func (world *World) spreadCity(ic int, suitability [][]float64, merge bool) int {
+	grid := world.Grid
+	free := func(y, x int) bool {
+		st := grid.Squares[y][x]
+		return st.Terrain == TERRAIN_LAND && st.Feature == FEATURE_NONE && !st.IsFrozen() && !grid.OnBorder(y, x)
+	}
+	touches := func(y, x, ic int) bool {
+		for _, dir := range DIR_NEXT {
+			if ny, nx, ok := grid.Neighbour(y, x, dir); ok && grid.Squares[ny][nx].CityIndex != -1 && grid.Squares[ny][nx].CityIndex != ic {
+				return true
+			}
+		}
+		return false
+	}
+	for {
+		city := world.Cities[ic]
+		if len(city.Y) >= CITY_TIER_SQUARES[city.Tier] {
+			return ic
+		}
+		by, bx, best := -1, -1, 0.0
+		for i := range city.Y {
+			for _, dir := range DIR_NEXT {
+				ny, nx, ok := grid.Neighbour(city.Y[i], city.X[i], dir)
+				if !ok || !free(ny, nx) || (!merge && touches(ny, nx, ic)) {
+					continue
+				}
+				dy, dx := grid.Offset(city.CenterY, city.CenterX, ny, nx)
+				// poor land is still built on when there is nothing else
+				score := (.1 + suitability[ny][nx]) / float64(1+dy*dy+dx*dx)
+				if score > best {
+					by, bx, best = ny, nx, score
+				}
+			}
+		}
+		if by == -1 {
+			return ic
+		}
+		grid.Squares[by][bx].Feature = FEATURE_CITY
+		grid.Squares[by][bx].CityIndex = ic
+		city.AddSquare(by, bx)
+		if !merge {
+			continue
+		}
+		for _, dir := range DIR_NEXT {
+			ny, nx, ok := grid.Neighbour(by, bx, dir)
+			if other := grid.Squares[ny][nx].CityIndex; ok && other != -1 && other != ic {
+				ic = world.mergeCities(ic, other)
+			}
+		}
+	}
+}
+
+// mergeCities makes a conurbation of two cities, the capital or else the
+// bigger one taking the other in; two capitals stay apart
+func (world *World) mergeCities(a, b int) int {
+	ca, cb := world.Cities[a], world.Cities[b]
+	if ca.Tier == CITY_TIER_CAPITAL && cb.Tier == CITY_TIER_CAPITAL {
+		return a
+	}
+	if cb.Tier == CITY_TIER_CAPITAL || (ca.Tier != CITY_TIER_CAPITAL && cb.Population > ca.Population) {
+		a, b, ca, cb = b, a, cb, ca
+	}
+	for i := range cb.Y {
+		ca.AddSquare(cb.Y[i], cb.X[i])
+		world.Grid.Squares[cb.Y[i]][cb.X[i]].CityIndex = a
+	}
+	ca.Population += cb.Population
+	ca.setTier()
+	world.Cities[b] = nil
+	return a
+}
+
+// growCities runs CityGrowth turns in which every city gains people, the
+// more so the better its spot, and spreads to house them, merging with the
+// cities it runs into; spots are the suitabilities of the cities' spots
+func (world *World) growCities(ctx context.Context, suitability [][]float64, spots []float64) error {
+	grid := world.Grid
+	maxSpot := 0.0
+	for _, s := range spots {
+		maxSpot = math.Max(maxSpot, s)
+	}
+	for turn := 0; turn < grid.CityGrowth; turn++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for ic, city := range world.Cities {
+			if city == nil {
+				continue
+			}
+			rate := CITY_GROWTH_PCT / 100 * (.5 + .5*spots[ic]/maxSpot)
+			city.Population += int(float64(city.Population) * rate * (.5 + world.rng.Float64()))
+			city.setTier()
+			world.spreadCity(ic, suitability, true)
+		}
+		world.report(STAGE_CITIES, grid.NbCities+turn+1, grid.NbCities+grid.CityGrowth)
+	}
+
+	// the cities taken in by others leave holes in the list
+	cities := world.Cities[:0]
+	for _, city := range world.Cities {
+		if city == nil {
+			continue
+		}
+		for i := range city.Y {
+			grid.Squares[city.Y[i]][city.X[i]].CityIndex = len(cities)
+		}
+		cities = append(cities, city)
+	}
+	if merged := len(world.Cities) - len(cities); merged > 0 {
+		world.logf("cities: %v merged into conurbations", merged)
+	}
+	world.Cities = cities
+	return nil
+}
@@ -0,0 +1,22 @@
+package lgc
+
+import "testing"
+
+// a city covers the squares of its tier, and its tier follows its people
+func TestCityFootprint(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		cfg := testConfig(seed, "quick")
+		cfg.NbCities = 20
+		world := testWorld(t, cfg)
+		for ic, city := range world.Cities {
+			if n := len(city.Y); n == 0 || n > CITY_TIER_SQUARES[city.Tier] {
+				t.Errorf("seed %v: %v %v covers %v squares, expected 1 to %v", seed, city.TierName(), ic, n, CITY_TIER_SQUARES[city.Tier])
+			}
+			tier := city.Tier
+			city.setTier()
+			if city.Tier != tier {
+				t.Errorf("seed %v: %v %v of %v people, expected a %v", seed, CITY_TIER_NAMES[tier], ic, city.Population, city.TierName())
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package lgc
+
+import (
+	"context"
+	"image/color"
+	"math"
+)
+
+// Whittaker biomes, water squares have none
+const (
+	BIOME_NONE = iota
+	BIOME_DESERT
+	BIOME_STEPPE
+	BIOME_FOREST
+	BIOME_RAINFOREST
+	BIOME_TUNDRA
+	BIOME_ICE
+)
+
+var BIOME_NAMES = [...]string{
+	BIOME_NONE:       "none",
+	BIOME_DESERT:     "desert",
+	BIOME_STEPPE:     "steppe",
+	BIOME_FOREST:     "forest",
+	BIOME_RAINFOREST: "rainforest",
+	BIOME_TUNDRA:     "tundra",
+	BIOME_ICE:        "ice",
+}
+
+// weight in percent of each biome in CitySuitability, none on the ice
+var BIOME_HABITABILITY = [...]int{
+	BIOME_DESERT:     15,
+	BIOME_STEPPE:     60,
+	BIOME_FOREST:     100,
+	BIOME_RAINFOREST: 40,
+	BIOME_TUNDRA:     15,
+	BIOME_ICE:        0,
+}
+
+// base land colours, shaded by the height
+var BIOME_COLORS = [...]color.RGBA{
+	BIOME_DESERT:     {238, 206, 140, 255},
+	BIOME_STEPPE:     {196, 200, 100, 255},
+	BIOME_RAINFOREST: {30, 150, 40, 255},
+	BIOME_TUNDRA:     {150, 160, 130, 255},
+	BIOME_ICE:        {235, 240, 245, 255},
+}
+
+// climate tuning, temperatures in degrees and precipitation in mm a year
+const (
+	CLIMATE_EQUATOR_TEMP float64 = 28
+	CLIMATE_POLE_TEMP    float64 = -30
+	// cooling from the coast to the highest peak
+	CLIMATE_LAPSE float64 = 15
+	// moisture picked up over each water square, and share of it falling on flat land
+	CLIMATE_EVAPORATION float64 = .2
+	CLIMATE_RAIN_RATE   float64 = .04
+	// share of the rain on land evaporating again from plants and soil
+	CLIMATE_RECYCLING float64 = .6
+	// extra rain for a climb of the whole 0..255 land scale
+	CLIMATE_OROGRAPHIC float64 = 8
+	CLIMATE_MAX_RAIN   float64 = 3000
+
+	CLIMATE_ICE_TEMP        = -10
+	CLIMATE_TUNDRA_TEMP     = 0
+	CLIMATE_RAINFOREST_TEMP = 20
+	CLIMATE_DESERT_RAIN     = 250
+	CLIMATE_STEPPE_RAIN     = 500
+	CLIMATE_RAINFOREST_RAIN = 2000
+)
+
+// Latitude of row y in degrees, 90 on the top row and -90 at the bottom
+func (cfg *Config) Latitude(y int) float64 {
+	return 90 - (float64(y)+.5)/float64(cfg.Height)*180
+}
+
+// rainfall of a latitude from 0 to 1: wet at the equator, dry around 30°,
+// wet again around 60° and dry at the poles
+func rainBelt(lat float64) float64 {
+	return (.55 + .45*math.Cos(lat*6*math.Pi/180)) * (1 - math.Abs(lat)/180)
+}
+
+// prevailing wind along x: trade winds and polar easterlies blow west,
+// westerlies blow east
+func windX(lat float64) int {
+	if a := math.Abs(lat); a >= 30 && a < 60 {
+		return 1
+	}
+	return -1
+}
+
+// Biome classifies a climate
+func Biome(temperature, precipitation int) int {
+	switch {
+	case temperature < CLIMATE_ICE_TEMP:
+		return BIOME_ICE
+	case temperature < CLIMATE_TUNDRA_TEMP:
+		return BIOME_TUNDRA
+	case precipitation < CLIMATE_DESERT_RAIN:
+		return BIOME_DESERT
+	case precipitation < CLIMATE_STEPPE_RAIN:
+		return BIOME_STEPPE
+	case temperature >= CLIMATE_RAINFOREST_TEMP && precipitation >= CLIMATE_RAINFOREST_RAIN:
+		return BIOME_RAINFOREST
+	}
+	return BIOME_FOREST
+}
+
+// AddClimate gives every square a temperature from its latitude and height,
+// and a precipitation from the wind carrying moisture from the water over
+// the land, then gives the land its biome
+func (world *World) AddClimate(ctx context.Context) error {
+	grid := world.Grid
+	el := elevations(grid)
+	counts := make([]int, len(BIOME_NAMES))
+	shelves := 0
+	for y := range grid.Squares {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		world.report(STAGE_CLIMATE, y, grid.Height)
+		lat := grid.Latitude(y)
+		belt := rainBelt(lat)
+
+		// upwind of the map is the ocean, a wrapping row goes round twice
+		// so its start gets the moisture from its end
+		dx := windX(lat)
+		x0 := 0
+		if dx < 0 {
+			x0 = grid.Width - 1
+		}
+		passes := 1
+		if grid.ConnectX {
+			passes = 2
+		}
+		moisture := 1.0
+		for i := 0; i < passes*grid.Width; i++ {
+			x := x0 + dx*(i%grid.Width)
+			st := grid.Squares[y][x]
+			if st.IsWater() {
+				moisture += (1 - moisture) * CLIMATE_EVAPORATION
+			}
+			rain := moisture * CLIMATE_RAIN_RATE
+			if !st.IsWater() {
+				_, px := grid.Inside(y, x-dx)
+				if climb := el[y][x] - math.Max(el[y][px], 0); climb > 0 {
+					rain += moisture * climb / 255 * CLIMATE_OROGRAPHIC * CLIMATE_RAIN_RATE
+				}
+				if rain > moisture {
+					rain = moisture
+				}
+				moisture -= rain * (1 - CLIMATE_RECYCLING)
+			}
+			if i < (passes-1)*grid.Width {
+				continue
+			}
+
+			height := math.Max(el[y][x], 0)
+			st.Temperature = int(CLIMATE_EQUATOR_TEMP - (CLIMATE_EQUATOR_TEMP-CLIMATE_POLE_TEMP)*(1-math.Cos(lat*math.Pi/180)) - CLIMATE_LAPSE*height/255)
+			st.Precipitation = int(belt * rain / CLIMATE_RAIN_RATE * CLIMATE_MAX_RAIN)
+			st.Biome = BIOME_NONE
+			if st.Terrain == TERRAIN_LAND || st.Terrain == TERRAIN_MOUNTAIN {
+				st.Biome = Biome(st.Temperature, st.Precipitation)
+				counts[st.Biome]++
+			} else if st.IsWater() && st.Temperature < CLIMATE_SEA_ICE_TEMP {
+				st.Biome = BIOME_ICE
+				shelves++
+			}
+		}
+	}
+	for b := BIOME_DESERT; b < len(counts); b++ {
+		world.logf("%v: %v", BIOME_NAMES[b], counts[b])
+	}
+	world.logf("ice shelves: %v", shelves)
+	return nil
+}
+
+// shade darkens a biome colour on high ground, v being the land value
+func shade(c color.RGBA, v uint8) (r, g, b uint8) {
+	k := 128 + int(v)/2
+	return uint8(int(c.R) * k / 255), uint8(int(c.G) * k / 255), uint8(int(c.B) * k / 255)
+}
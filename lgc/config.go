@@ -0,0 +1,177 @@
+package lgc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Logger receives progress messages, a *log.Logger is one
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// CountryCosts weigh the squares countries cross as they grow
+type CountryCosts struct {
+	// of every square, how far countries reach
+	Distance float64
+	// on top of it, the higher the more borders follow ridges and rivers
+	Mountain, River float64
+}
+
+type Config struct {
+	Width, Height             int
+	ConnectY, ConnectX        bool
+	Seed                      int64
+	RiverPct                  int
+	RiverMode                 int
+	NbCities, NbCountries     int
+	Frames, SpawnPower        int
+	SquareWidth, SquareHeight int
+	// share of land in percent, whatever the terrain gives if 0
+	LandPct int
+	// erosion droplets, none if 0, and their strength in percent
+	Erosion, ErosionPct int
+	// turns of city growth, none if 0
+	CityGrowth   int
+	CountryCosts CountryCosts
+	// particles if nil
+	Terrain  TerrainGenerator
+	Logger   Logger
+	Progress ProgressFunc
+}
+
+// NewConfig returns the default settings for a map of the given size
+func NewConfig(width, height int) *Config {
+	cfg := &Config{
+		Width:        width,
+		Height:       height,
+		Seed:         time.Now().UnixNano(),
+		RiverPct:     8,
+		ErosionPct:   50,
+		SquareWidth:  8,
+		SquareHeight: 8,
+		CountryCosts: CountryCosts{Distance: 1, Mountain: 6, River: 10},
+	}
+	cfg.NbCities = cfg.Magic()
+	cfg.NbCountries = cfg.Magic() / 5
+	cfg.Frames = cfg.Magic()
+	cfg.SpawnPower = cfg.Magic()
+	return cfg
+}
+
+func (cfg *Config) Validate() error {
+	if cfg.Width < 3 || cfg.Height < 3 {
+		return fmt.Errorf("map size %vx%v is too small, expected at least 3x3", cfg.Width, cfg.Height)
+	}
+	if cfg.RiverPct < 0 || cfg.RiverPct > 100 {
+		return fmt.Errorf("river percentage %v is not between 0 and 100", cfg.RiverPct)
+	}
+	if cfg.RiverMode != RIVER_MODE_WALK && cfg.RiverMode != RIVER_MODE_FLOW {
+		return fmt.Errorf("unknown river mode %v", cfg.RiverMode)
+	}
+	if cfg.LandPct < 0 || cfg.LandPct >= 100 {
+		return fmt.Errorf("land percentage %v is not between 0 and 99", cfg.LandPct)
+	}
+	if cfg.Erosion < 0 {
+		return fmt.Errorf("negative erosion droplet count %v", cfg.Erosion)
+	}
+	if cfg.ErosionPct < 0 || cfg.ErosionPct > 100 {
+		return fmt.Errorf("erosion strength %v is not between 0 and 100", cfg.ErosionPct)
+	}
+	if cfg.NbCities < 1 {
+		return fmt.Errorf("at least one city is needed, got %v", cfg.NbCities)
+	}
+	if cfg.NbCountries < 0 || cfg.NbCountries > cfg.NbCities {
+		return fmt.Errorf("%v countries cannot be made out of %v cities", cfg.NbCountries, cfg.NbCities)
+	}
+	if cfg.CityGrowth < 0 {
+		return fmt.Errorf("negative city growth turn count %v", cfg.CityGrowth)
+	}
+	if cc := cfg.CountryCosts; cc.Distance <= 0 || cc.Mountain < 0 || cc.River < 0 {
+		return fmt.Errorf("country costs %+v must be positive", cc)
+	}
+	if cfg.Frames < 0 {
+		return fmt.Errorf("negative frame count %v", cfg.Frames)
+	}
+	if cfg.SpawnPower < 1 {
+		return fmt.Errorf("spawn power must be at least 1, got %v", cfg.SpawnPower)
+	}
+	if cfg.SquareWidth < 4 || cfg.SquareHeight < 4 {
+		return fmt.Errorf("square size %vx%v is too small, expected at least 4x4", cfg.SquareWidth, cfg.SquareHeight)
+	}
+	return nil
+}
+
+func (cfg *Config) logf(format string, v ...interface{}) {
+	if cfg.Logger != nil {
+		cfg.Logger.Printf(format, v...)
+	}
+}
+
+// NewRand returns the random source of one generation, every stage must draw from it
+func (cfg *Config) NewRand() *rand.Rand {
+	return rand.New(rand.NewSource(cfg.Seed))
+}
+
+// handle with care
+func (cfg *Config) Surface() int {
+	return cfg.Width * cfg.Height
+}
+
+func (cfg *Config) Diagonal() int {
+	return cfg.Width + cfg.Height
+}
+
+func (cfg *Config) Magic() int {
+	return int(2.0 * math.Sqrt(float64(cfg.Diagonal())))
+}
+
+func (cfg *Config) MaxVal() int {
+	return cfg.Magic()
+}
+
+// Neighbour is the square next to y, x in direction dir, ok is false
+// across a side of the map that is not connected
+func (cfg *Config) Neighbour(y, x int, dir [2]int) (ny, nx int, ok bool) {
+	ny, nx = y+dir[0], x+dir[1]
+	if (!cfg.ConnectY && (ny < 0 || ny >= cfg.Height)) || (!cfg.ConnectX && (nx < 0 || nx >= cfg.Width)) {
+		return y, x, false
+	}
+	ny, nx = cfg.Inside(ny, nx)
+	return ny, nx, true
+}
+
+// OnBorder is true on the sides of the map that are not connected
+func (cfg *Config) OnBorder(y, x int) bool {
+	return (!cfg.ConnectY && (y == 0 || y == cfg.Height-1)) || (!cfg.ConnectX && (x == 0 || x == cfg.Width-1))
+}
+
+// Offset goes from y0, x0 to y1, x1, the short way round connected sides
+func (cfg *Config) Offset(y0, x0, y1, x1 int) (dy, dx int) {
+	dy, dx = y1-y0, x1-x0
+	if cfg.ConnectY && 2*Abs(dy) > cfg.Height {
+		dy -= Sign(dy) * cfg.Height
+	}
+	if cfg.ConnectX && 2*Abs(dx) > cfg.Width {
+		dx -= Sign(dx) * cfg.Width
+	}
+	return
+}
+
+func (cfg *Config) Inside(y, x int) (int, int) {
+	for y < 0 {
+		y += cfg.Height
+	}
+	for y >= cfg.Height {
+		y -= cfg.Height
+	}
+	for x < 0 {
+		x += cfg.Width
+	}
+	for x >= cfg.Width {
+		x -= cfg.Width
+	}
+	return y, x
+}
@@ -0,0 +1,247 @@
+package lgc
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+func (grid *Grid) DecorateFeatures(ctx context.Context, rng *rand.Rand, cities []*City) error {
+	// river width follows the flow when there is one
+	minFlow, maxFlow := 0, 0
+	for y := range grid.Squares {
+		for _, st := range grid.Squares[y] {
+			if st.Feature == FEATURE_RIVER && st.Flow > 0 {
+				if minFlow == 0 || st.Flow < minFlow {
+					minFlow = st.Flow
+				}
+				if st.Flow > maxFlow {
+					maxFlow = st.Flow
+				}
+			}
+		}
+	}
+
+	for y := range grid.Squares {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		grid.report(STAGE_DECORATION, y, grid.Height)
+		for x := range grid.Squares[y] {
+			switch st := grid.Squares[y][x]; st.Feature {
+			case FEATURE_RIVER:
+				var connections [len(DIR_NEXT)]bool
+				for i, dir := range DIR_NEXT {
+					ny, nx := grid.Inside(y+dir[0], x+dir[1])
+					other := grid.Squares[ny][nx]
+					if st.Flow > 0 {
+						// only where the water goes and where it comes from
+						connections[i] = st.FlowTo == i || ((other.Feature == FEATURE_RIVER || other.Terrain == TERRAIN_LAKE) && other.Flow > 0 && other.FlowTo == (i+2)%len(DIR_NEXT))
+					} else if other.IsWater() || other.Feature == FEATURE_RIVER {
+						connections[i] = true
+					}
+				}
+				width := 0
+				if maxFlow > minFlow && st.Flow > 0 {
+					width = RIVER_MIN_WIDTH + int(float64(RIVER_MAX_WIDTH-RIVER_MIN_WIDTH)*math.Log(float64(st.Flow)/float64(minFlow))/math.Log(float64(maxFlow)/float64(minFlow))+.5)
+				}
+				st.DrawRiver(rng, connections, width)
+			case FEATURE_CITY:
+				city := cities[st.CityIndex]
+				if err := st.DrawCity(city.Tier, city.CenterY == y && city.CenterX == x); err != nil {
+					return err
+				}
+			case FEATURE_COUNTRY_BORDER:
+				st.DrawCountryBorder()
+			case FEATURE_NONE:
+				var err error
+				switch {
+				case st.Terrain == TERRAIN_MAP_BORDER && st.IsFrozen():
+					err = st.DrawPole(rng)
+				case st.IsFrozen() && st.Terrain != TERRAIN_MOUNTAIN:
+					err = st.DrawIce(rng)
+				case st.Terrain == TERRAIN_LAKE:
+					err = st.DrawLake(rng)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if st := grid.Squares[y][x]; st.Road {
+				var connections [len(DIR_NEXT)]bool
+				for i, dir := range DIR_NEXT {
+					ny, nx, ok := grid.Neighbour(y, x, dir)
+					connections[i] = ok && (grid.Squares[ny][nx].Road || grid.Squares[ny][nx].CityIndex != -1)
+				}
+				st.DrawRoad(connections, st.IsBridge())
+			}
+		}
+	}
+	return nil
+}
+
+// shapes are drawn on SHAPE_SIZE×SHAPE_SIZE and scaled to the square size
+const SHAPE_SIZE int = 8
+
+func (st *SquareTerrain) Draw(shape string, colors map[byte]color.Color) error {
+	if len(shape) != SHAPE_SIZE*SHAPE_SIZE {
+		return fmt.Errorf("invalid shape size %v, expected %v", len(shape), SHAPE_SIZE*SHAPE_SIZE)
+	}
+
+	for y := range st.Colors {
+		for x := range st.Colors[y] {
+			if c := colors[shape[y*SHAPE_SIZE/len(st.Colors)*SHAPE_SIZE+x*SHAPE_SIZE/len(st.Colors[y])]]; c != color.Transparent {
+				st.Colors[y][x] = c
+			}
+		}
+	}
+	return nil
+}
+
+// river widths on SHAPE_SIZE, 0 is the full width
+const (
+	RIVER_MIN_WIDTH int = 2
+	RIVER_MAX_WIDTH int = SHAPE_SIZE - 2
+)
+
+func (st *SquareTerrain) DrawRiver(rng *rand.Rand, connections [len(DIR_NEXT)]bool, width int) {
+	h, w := len(st.Colors), len(st.Colors[0])
+	bh, bw := h-4, w-4
+	if width > 0 {
+		bh, bw = width*h/SHAPE_SIZE, width*w/SHAPE_SIZE
+		if bh < 1 {
+			bh = 1
+		}
+		if bw < 1 {
+			bw = 1
+		}
+	}
+	top, left := (h-bh)/2, (w-bw)/2
+	var riverY, riverX []int
+	for i, dir := range DIR_NEXT {
+		if connections[i] {
+			if dir[0] == 0 {
+				x0, x1 := left+bw, w
+				if dir[1] < 0 {
+					x0, x1 = 0, left
+				}
+				for sy := top; sy < top+bh; sy++ {
+					for sx := x0; sx < x1; sx++ {
+						riverY = append(riverY, sy)
+						riverX = append(riverX, sx)
+					}
+				}
+			} else if dir[1] == 0 {
+				y0, y1 := top+bh, h
+				if dir[0] < 0 {
+					y0, y1 = 0, top
+				}
+				for sx := left; sx < left+bw; sx++ {
+					for sy := y0; sy < y1; sy++ {
+						riverY = append(riverY, sy)
+						riverX = append(riverX, sx)
+					}
+				}
+			}
+		}
+	}
+	for sy := top; sy < top+bh; sy++ {
+		for sx := left; sx < left+bw; sx++ {
+			if rng.Intn(h*w/3) < 1 {
+				st.Colors[sy][sx] = color.RGBA{
+					R: uint8(st.Val / 4),
+					G: uint8(st.Val / 2),
+					B: uint8((st.Val + 255) / 2),
+					A: 255,
+				}
+			} else {
+				st.Colors[sy][sx] = color.RGBA{
+					R: uint8(st.Val / 4),
+					G: uint8(st.Val / 2),
+					B: uint8(st.Val),
+					A: 255,
+				}
+			}
+		}
+	}
+	for i := range riverY {
+		st.Colors[riverY[i]][riverX[i]] = color.RGBA{
+			R: uint8(st.Val / 4),
+			G: uint8(st.Val / 2),
+			B: uint8(st.Val),
+			A: 255,
+		}
+	}
+}
+
+// DrawCity draws one square of a city of that tier, a capital having its
+// palace on its centre
+func (st *SquareTerrain) DrawCity(tier int, center bool) error {
+	colors := map[byte]color.Color{
+		't': color.RGBA{144, 71, 17, 255},
+		's': color.RGBA{83, 42, 8, 255},
+		'w': color.RGBA{203, 101, 27, 255},
+		'd': color.RGBA{156, 167, 174, 255},
+		'n': color.RGBA{212, 220, 224, 255},
+		'f': color.RGBA{200, 40, 60, 255},
+		'.': color.Transparent,
+	}
+
+	shape := "tt.tttttswtswwwwwdtwdwnwwdtwdwnwttttttttttt.ttttswwtswwwwdwtwdnw"
+	switch {
+	case tier == CITY_TIER_HAMLET:
+		shape = "...................tt.....tsww...tswwww...twdw....twdw.........."
+	case tier == CITY_TIER_TOWN:
+		shape = "..tsww...tswwww.tswwwwwwttttttttssssssstwwdwnwstwwdwwwsttttttttt"
+	case tier == CITY_TIER_CAPITAL && center:
+		shape = "...f.......ff......t......tst....tswwt..ttwnwwtttwdwdwdttttttttt"
+	}
+
+	return st.Draw(shape, colors)
+}
+
+// DrawRoad draws a road from the middle of the square to its connected
+// sides, wider and darker on a bridge
+func (st *SquareTerrain) DrawRoad(connections [len(DIR_NEXT)]bool, bridge bool) {
+	h, w := len(st.Colors), len(st.Colors[0])
+	c := color.RGBA{150, 110, 70, 255}
+	bh, bw := (h+4)/8, (w+4)/8
+	if bridge {
+		c = color.RGBA{95, 65, 40, 255}
+		bh, bw = 3*h/8, 3*w/8
+	}
+	top, left := (h-bh)/2, (w-bw)/2
+	fill := func(y0, y1, x0, x1 int) {
+		for sy := y0; sy < y1; sy++ {
+			for sx := x0; sx < x1; sx++ {
+				st.Colors[sy][sx] = c
+			}
+		}
+	}
+	fill(top, top+bh, left, left+bw)
+	for i, dir := range DIR_NEXT {
+		if !connections[i] {
+			continue
+		}
+		switch {
+		case dir[1] > 0:
+			fill(top, top+bh, left+bw, w)
+		case dir[1] < 0:
+			fill(top, top+bh, 0, left)
+		case dir[0] > 0:
+			fill(top+bh, h, left, left+bw)
+		case dir[0] < 0:
+			fill(0, top, left, left+bw)
+		}
+	}
+}
+
+func (st *SquareTerrain) DrawCountryBorder() {
+	for sy := 0; sy < 2; sy++ {
+		for sx := 0; sx < 2; sx++ {
+			st.Colors[len(st.Colors)/2-1+sy][len(st.Colors[0])/2-1+sx] = color.RGBA{255, 0, 0, 255}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package lgc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+func PrintPPM(grid *Grid, out io.Writer) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "P3")
+	fmt.Fprintln(w, "#", "Seed", grid.Seed)
+	fmt.Fprintln(w, grid.Width*grid.SquareWidth, grid.Height*grid.SquareHeight, 255)
+	for y := range grid.Squares {
+		for sy := 0; sy < grid.SquareHeight; sy++ {
+			for x := range grid.Squares[y] {
+				for sx := range grid.Squares[y][x].Colors[sy] {
+					r, g, b, _ := grid.Squares[y][x].Colors[sy][sx].RGBA()
+					fmt.Fprint(w, r>>8, " ", g>>8, " ", b>>8, " ")
+				}
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return w.Flush()
+}
+
+func PrintPNG(grid *Grid, w io.Writer) error {
+	img := image.NewRGBA(image.Rect(0, 0, grid.Width*grid.SquareWidth, grid.Height*grid.SquareHeight))
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			for sy := range grid.Squares[y][x].Colors {
+				for sx := range grid.Squares[y][x].Colors[sy] {
+					img.Set(x*grid.SquareWidth+sx, y*grid.SquareHeight+sy, grid.Squares[y][x].Colors[sy][sx])
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+
+	// metadata goes right after the IHDR chunk
+	data := buf.Bytes()
+	ihdrEnd := len("\x89PNG\r\n\x1a\n") + 4 + len("IHDR") + 13 + 4
+	for _, chunk := range [][]byte{
+		data[:ihdrEnd],
+		pngTextChunk("Seed", strconv.FormatInt(grid.Seed, 10)),
+		pngTextChunk("Size", fmt.Sprint(grid.Width, "x", grid.Height)),
+		data[ihdrEnd:],
+	} {
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pngTextChunk(keyword, text string) []byte {
+	data := append([]byte(keyword), 0)
+	data = append(data, text...)
+	chunk := make([]byte, 4, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	chunk = append(chunk, "tEXt"...)
+	chunk = append(chunk, data...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+	return append(chunk, crc...)
+}
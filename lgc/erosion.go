@@ -0,0 +1,164 @@
+package lgc
+
+import "context"
+
+// erosion tuning, at ErosionPct 100
+const (
+	// share of its drop a droplet can carry, of the missing load it digs,
+	// and of the excess load it leaves when too heavy
+	EROSION_CAPACITY float64 = 1
+	EROSION_RATE     float64 = .3
+	EROSION_DEPOSIT  float64 = .3
+	// water left after each move
+	EROSION_EVAPORATION float64 = .98
+	// steepest stable slope, and share of the excess moved by each thermal pass
+	EROSION_TALUS  float64 = 16
+	EROSION_SLUMP  float64 = .25
+	EROSION_PASSES int     = 10
+)
+
+// elevation grows uphill on land and downwards in the sea, with 0 on the coast
+func elevations(grid *Grid) [][]float64 {
+	el := newHeights(grid.Config)
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if st.IsWater() {
+				el[y][x] = -float64(st.Val)
+			} else {
+				el[y][x] = float64(255 - st.Val)
+			}
+		}
+	}
+	return el
+}
+
+// lowestNeighbour is the lowest of y, x and the squares around it, never
+// across a side of the map that is not connected
+func (grid *Grid) lowestNeighbour(el [][]float64, y, x int) (ly, lx int) {
+	ly, lx = y, x
+	for _, dir := range DIR_SQUARE {
+		ny, nx, ok := grid.Neighbour(y, x, dir)
+		if ok && el[ny][nx] < el[ly][lx] {
+			ly, lx = ny, nx
+		}
+	}
+	return
+}
+
+// Erode runs Erosion droplets down the land then lets steep slopes slump,
+// land stays land and the sea is left alone
+func (world *World) Erode(ctx context.Context) error {
+	grid := world.Grid
+	if grid.Erosion == 0 || world.NbLand == 0 {
+		return nil
+	}
+	strength := float64(grid.ErosionPct) / 100
+	el := elevations(grid)
+	isLand := func(y, x int) bool {
+		return grid.Squares[y][x].Terrain != TERRAIN_SEA
+	}
+	// a land square never goes down to the sea level
+	lower := func(y, x int, amount float64) float64 {
+		if el[y][x]-amount < 1 {
+			amount = el[y][x] - 1
+		}
+		if amount < 0 {
+			amount = 0
+		}
+		el[y][x] -= amount
+		return amount
+	}
+
+	maxSteps := 2 * grid.Magic()
+	for i := 0; i < grid.Erosion; i++ {
+		if i%1000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			world.report(STAGE_EROSION, i, grid.Erosion)
+		}
+		y, x := world.rng.Intn(grid.Height), world.rng.Intn(grid.Width)
+		for !isLand(y, x) {
+			y, x = world.rng.Intn(grid.Height), world.rng.Intn(grid.Width)
+		}
+
+		sediment, water := 0.0, 1.0
+		for step := 0; step < maxSteps; step++ {
+			ny, nx := grid.lowestNeighbour(el, y, x)
+			drop := el[y][x] - el[ny][nx]
+			if drop <= 0 {
+				// stuck in a pit, fill it
+				el[y][x] += sediment
+				break
+			}
+			if !isLand(ny, nx) {
+				// the load is lost at sea
+				break
+			}
+			capacity := drop * water * EROSION_CAPACITY * strength
+			if sediment > capacity {
+				d := (sediment - capacity) * EROSION_DEPOSIT
+				// never deposit above the next square, water must keep flowing
+				if d > drop/2 {
+					d = drop / 2
+				}
+				el[y][x] += d
+				sediment -= d
+			} else {
+				// digging more than half the drop would make a pit
+				take := (capacity - sediment) * EROSION_RATE * strength
+				if take > drop/2 {
+					take = drop / 2
+				}
+				sediment += lower(y, x, take)
+			}
+			water *= EROSION_EVAPORATION
+			y, x = ny, nx
+		}
+	}
+
+	// thermal slumping
+	for pass := 0; pass < EROSION_PASSES; pass++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for y := range el {
+			for x := range el[y] {
+				if !isLand(y, x) {
+					continue
+				}
+				ny, nx := grid.lowestNeighbour(el, y, x)
+				if !isLand(ny, nx) {
+					continue
+				}
+				if excess := el[y][x] - el[ny][nx] - EROSION_TALUS; excess > 0 {
+					el[ny][nx] += lower(y, x, excess*EROSION_SLUMP*strength)
+				}
+			}
+		}
+	}
+
+	// back to land values, spread over 0..254 again
+	minEl, maxEl := -1.0, -1.0
+	for y := range el {
+		for x := range el[y] {
+			if isLand(y, x) {
+				if minEl == -1 || el[y][x] < minEl {
+					minEl = el[y][x]
+				}
+				if maxEl == -1 || el[y][x] > maxEl {
+					maxEl = el[y][x]
+				}
+			}
+		}
+	}
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if isLand(y, x) && maxEl > minEl {
+				st.Val = int((maxEl - el[y][x]) / (maxEl - minEl) * 254)
+			}
+		}
+	}
+	world.logf("erosion: %v droplets", grid.Erosion)
+	return nil
+}
@@ -0,0 +1,37 @@
+package lgc
+
+import "testing"
+
+// droplets and slumps only go across the sides of the map that are connected
+func TestLowestNeighbour(t *testing.T) {
+	// the lowest squares are in the corners, and lower still across the sides
+	el := [][]float64{
+		{5, 6, 6, 3},
+		{6, 9, 9, 6},
+		{6, 9, 9, 6},
+		{2, 6, 6, 1},
+	}
+	for _, tc := range []struct {
+		name               string
+		connectY, connectX bool
+		y, x, ly, lx       int
+	}{
+		{"flat corner", false, false, 0, 0, 0, 0},
+		{"flat middle", false, false, 1, 1, 0, 0},
+		{"flat side", false, false, 0, 2, 0, 3},
+		{"cylinder corner", false, true, 0, 0, 0, 3},
+		{"cylinder bottom", false, true, 3, 0, 3, 3},
+		{"rolled up corner", true, false, 0, 0, 3, 0},
+		{"torus corner", true, true, 0, 0, 3, 3},
+		{"torus middle", true, true, 2, 2, 3, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig(4, 4)
+			cfg.ConnectY, cfg.ConnectX = tc.connectY, tc.connectX
+			ly, lx := NewGrid(cfg).lowestNeighbour(el, tc.y, tc.x)
+			if ly != tc.ly || lx != tc.lx {
+				t.Errorf("lowest around %v,%v is %v,%v, expected %v,%v", tc.y, tc.x, ly, lx, tc.ly, tc.lx)
+			}
+		})
+	}
+}
@@ -0,0 +1,433 @@
+package lgc
+
+import (
+	"fmt"
+	"image/color"
+	"math/rand"
+)
+
+const (
+	TERRAIN_SEA = iota
+	TERRAIN_LAND
+	TERRAIN_MOUNTAIN
+	TERRAIN_MAP_BORDER
+	TERRAIN_LAKE
+)
+
+const (
+	FEATURE_NONE = iota
+	FEATURE_RIVER
+	FEATURE_CITY
+	FEATURE_COUNTRY_BORDER
+)
+
+var (
+	DIR_NEXT [4][2]int = [4][2]int{
+		{0, 1}, {1, 0}, {0, -1}, {-1, 0},
+	}
+	DIR_SQUARE [8][2]int = [8][2]int{
+		{0, 1}, {1, 0}, {0, -1}, {-1, 0},
+		{-1, -1}, {-1, 1}, {1, -1}, {1, 1},
+	}
+)
+
+func HSVtoRGBA(H int, S, V float64) (out color.RGBA) {
+	for H < 0 {
+		H += 360
+	}
+	for H > 360 {
+		H -= 360
+	}
+	C := V * S
+	X := C * float64(1-Abs(H/60%2-1))
+	m := V - C
+	var r, g, b float64
+	if H < 60 {
+		r, g, b = C, X, 0.0
+	} else if H < 120 {
+		r, g, b = X, C, 0.0
+	} else if H < 180 {
+		r, g, b = 0.0, C, X
+	} else if H < 240 {
+		r, g, b = 0.0, X, C
+	} else if H < 300 {
+		r, g, b = X, 0.0, C
+	} else {
+		r, g, b = C, 0.0, X
+	}
+	out = color.RGBA{
+		R: uint8((r + m) * 255),
+		G: uint8((g + m) * 255),
+		B: uint8((b + m) * 255),
+	}
+	if out.R > 255 {
+		out.R = 255
+	} else if out.R < 0 {
+		out.R = 0
+	}
+	if out.G > 255 {
+		out.G = 255
+	} else if out.G < 0 {
+		out.G = 0
+	}
+	if out.B > 255 {
+		out.B = 255
+	} else if out.B < 0 {
+		out.B = 0
+	}
+	return
+}
+
+type Grid struct {
+	*Config
+	Squares [][]*SquareTerrain
+}
+
+func NewGrid(cfg *Config) *Grid {
+	grid := &Grid{
+		Config:  cfg,
+		Squares: make([][]*SquareTerrain, cfg.Height),
+	}
+	for y := range grid.Squares {
+		grid.Squares[y] = make([]*SquareTerrain, cfg.Width)
+	}
+	return grid
+}
+
+type SquareTerrain struct {
+	Val          int
+	Terrain      int
+	Feature      int
+	Colors       [][]color.Color
+	CountryIndex int
+	// land squares draining through this one, with RIVER_MODE_FLOW,
+	// and the DIR_NEXT index of where the water goes
+	Flow, FlowTo int
+	// degrees, mm of rain a year, and the BIOME_* of land squares
+	Temperature, Precipitation int
+	Biome                      int
+	// SEA_DEPTH_* of sea squares
+	SeaDepth int
+	// index in World.Landmasses, -1 off land
+	LandmassIndex int
+	// index in World.Cities, -1 outside cities
+	CityIndex int
+	// a road goes through, over a bridge on a river
+	Road bool
+}
+
+func NewSquareTerrain(cfg *Config, val int) *SquareTerrain {
+	st := &SquareTerrain{
+		Val:       val,
+		Feature:   FEATURE_NONE,
+		CityIndex: -1,
+		Colors:    make([][]color.Color, cfg.SquareHeight),
+	}
+	for y := range st.Colors {
+		st.Colors[y] = make([]color.Color, cfg.SquareWidth)
+	}
+	return st
+}
+
+func (grid *Grid) Count(f func(st *SquareTerrain) bool) int {
+	var n int
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			if f(grid.Squares[y][x]) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func (st *SquareTerrain) SetRGBA(r, g, b, a uint8) {
+	for y := range st.Colors {
+		for x := range st.Colors[y] {
+			st.Colors[y][x] = color.RGBA{r, g, b, a}
+		}
+	}
+}
+
+func (st *SquareTerrain) SetColor(c color.Color) {
+	for y := range st.Colors {
+		for x := range st.Colors[y] {
+			st.Colors[y][x] = c
+		}
+	}
+}
+
+type River struct {
+	y, x      []int
+	pathStack []int
+	Level     int
+}
+
+func NewRiver(grid *Grid, rng *rand.Rand) (*River, error) {
+	if grid.Count(func(st *SquareTerrain) bool { return st.Terrain == TERRAIN_MOUNTAIN }) == 0 {
+		return nil, fmt.Errorf("no mountain to start a river from")
+	}
+	y, x := rng.Intn(grid.Height), rng.Intn(grid.Width)
+	for grid.Squares[y][x].Terrain != TERRAIN_MOUNTAIN {
+		y, x = rng.Intn(grid.Height), rng.Intn(grid.Width)
+	}
+	grid.Squares[y][x].Feature = FEATURE_RIVER
+	return &River{
+		y:         []int{y},
+		x:         []int{x},
+		pathStack: []int{0},
+		Level:     int(grid.Squares[y][x].Val),
+	}, nil
+}
+
+func (r *River) Y() int {
+	return r.y[r.i()]
+}
+
+func (r *River) X() int {
+	return r.x[r.i()]
+}
+
+func (r *River) i() int {
+	return r.pathStack[len(r.pathStack)-1]
+}
+
+func (r *River) Len() int {
+	return len(r.pathStack)
+}
+
+func (r *River) IsAt(y, x int) bool {
+	for _, i := range r.pathStack {
+		if r.y[i] == y && r.x[i] == x {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *River) WasAt(y, x int) bool {
+	for i := range r.y {
+		if r.y[i] == y && r.x[i] == x {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *River) Move(y, x int) {
+	r.pathStack = append(r.pathStack, len(r.y))
+	r.y = append(r.y, y)
+	r.x = append(r.x, x)
+}
+
+func (r *River) GoBack() {
+	r.pathStack = r.pathStack[:len(r.pathStack)-1]
+}
+
+func (r *River) SetColor(c color.Color, grid *Grid) {
+	for i := range r.y {
+		grid.Squares[r.y[i]][r.x[i]].SetColor(c)
+	}
+}
+
+type City struct {
+	CenterY, CenterX int
+	Y, X             []int
+	Population       int
+	// CITY_TIER_*
+	Tier int
+}
+
+func NewCity(y, x int) *City {
+	return &City{
+		CenterY: y,
+		CenterX: x,
+		Y:       []int{y},
+		X:       []int{x},
+	}
+}
+
+func (c *City) Has(y, x int) bool {
+	for i := range c.Y {
+		if c.Y[i] == y && c.X[i] == x {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *City) AddSquare(y, x int) {
+	if c.Has(y, x) {
+		return
+	}
+	c.Y = append(c.Y, y)
+	c.X = append(c.X, x)
+}
+
+type Country struct {
+	Cities           []*City
+	Y, X             []int
+	BorderY, BorderX []int
+	Color            color.Color
+	CG               *CountryGroup
+}
+
+func NewCountry(city *City, cg *CountryGroup, color color.Color) *Country {
+	country := &Country{
+		Cities:  []*City{city},
+		Y:       city.Y,
+		X:       city.X,
+		BorderY: city.Y,
+		BorderX: city.X,
+		Color:   color,
+		CG:      cg,
+	}
+	country.SharpenBorder()
+	return country
+}
+
+func (c *Country) Surface() int {
+	return len(c.Y)
+}
+
+func (c *Country) HasInBorder(y, x int) bool {
+	for i := range c.BorderY {
+		if c.BorderY[i] == y && c.BorderX[i] == x {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Country) Take(y, x int) {
+	c.Y = append(c.Y, y)
+	c.X = append(c.X, x)
+	c.BorderY = append(c.BorderY, y)
+	c.BorderX = append(c.BorderX, x)
+}
+
+func (c *Country) TakeCity(city *City) {
+	c.Cities = append(c.Cities, city)
+	c.Y = append(c.Y, city.Y...)
+	c.X = append(c.X, city.X...)
+	c.BorderY = append(c.BorderY, city.Y...)
+	c.BorderX = append(c.BorderX, city.X...)
+}
+
+func (c *Country) Leave(y, x int) {
+	for i := range c.Y {
+		if c.Y[i] == y && c.X[i] == x {
+			if i < len(c.Y)-1 {
+				c.Y = append(c.Y[:i], c.Y[i+1:]...)
+				c.X = append(c.X[:i], c.X[i+1:]...)
+			} else {
+				c.Y = c.Y[:i]
+				c.X = c.X[:i]
+			}
+			break
+		}
+	}
+	for i := range c.BorderY {
+		if c.BorderY[i] == y && c.BorderX[i] == x {
+			if i < len(c.BorderY)-1 {
+				c.BorderY = append(c.BorderY[:i], c.BorderY[i+1:]...)
+				c.BorderX = append(c.BorderX[:i], c.BorderX[i+1:]...)
+			} else {
+				c.BorderY = c.BorderY[:i]
+				c.BorderX = c.BorderX[:i]
+			}
+			break
+		}
+	}
+}
+
+func (c *Country) ClosestCity(y, x int) *City {
+	dist := -1
+	var cc *City
+	for _, city := range c.Cities {
+		if dist == -1 || (city.CenterY-y)*(city.CenterY-y)+(city.CenterX-x)*(city.CenterX-x) < dist {
+			cc = city
+		}
+	}
+	return cc
+}
+
+func (c *Country) SharpenBorder() {
+	ic := c.CG.Index(c)
+	if ic == -1 {
+		return
+	}
+	// new slices, the border may share its array with the squares
+	var borderY, borderX []int
+	for i := range c.BorderY {
+		y, x := c.BorderY[i], c.BorderX[i]
+		for _, dir := range DIR_NEXT {
+			oy, ox := c.CG.Grid.Inside(y+dir[0], x+dir[1])
+			if c.CG.Grid.Squares[oy][ox].CountryIndex != ic {
+				borderY, borderX = append(borderY, y), append(borderX, x)
+				break
+			}
+		}
+	}
+	c.BorderY, c.BorderX = borderY, borderX
+}
+
+func (c *Country) Center() (centerY, centerX int) {
+	for i := range c.Y {
+		centerY += c.Y[i]
+		centerX += c.X[i]
+	}
+	centerY /= len(c.Y)
+	centerX /= len(c.X)
+	return
+}
+
+type CountryGroup struct {
+	countries []*Country
+	Grid      *Grid
+}
+
+func NewCountryGroup(grid *Grid) *CountryGroup {
+	return &CountryGroup{
+		countries: []*Country{},
+		Grid:      grid,
+	}
+}
+
+func (cg *CountryGroup) AddCountry(country *Country) {
+	cg.countries = append(cg.countries, country)
+}
+
+func (cg *CountryGroup) Index(country *Country) int {
+	for i := range cg.countries {
+		if cg.countries[i] == country {
+			return i
+		}
+	}
+	return -1
+}
+
+func (cg *CountryGroup) CountryCount() int {
+	return len(cg.countries)
+}
+
+func (cg *CountryGroup) Get(i int) *Country {
+	return cg.countries[i]
+}
+
+func (cg *CountryGroup) Surface() int {
+	var s int
+	for _, c := range cg.countries {
+		s += c.Surface()
+	}
+	return s
+}
+
+func (cg *CountryGroup) HasInBorders(y, x int) bool {
+	for _, c := range cg.countries {
+		if c.HasInBorder(y, x) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package lgc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+)
+
+// TerrainGenerator makes the signed height field AddFeaturesToTerrain starts from,
+// positive squares are land and the others sea
+type TerrainGenerator interface {
+	GenerateTerrain(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error)
+}
+
+// MountainGenerator is a TerrainGenerator that also knows where mountains are,
+// AddMountains then follows its mask
+type MountainGenerator interface {
+	TerrainGenerator
+	GenerateMountains(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, [][]bool, error)
+}
+
+// TerrainFunc turns a plain function into a TerrainGenerator
+type TerrainFunc func(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error)
+
+func (f TerrainFunc) GenerateTerrain(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error) {
+	return f(ctx, cfg, rng)
+}
+
+// generators selectable by name, with their default settings
+var TERRAIN_GENERATORS = map[string]TerrainGenerator{
+	"particles":      TerrainFunc(GenerateTerrain),
+	"quick":          TerrainFunc(GenerateTerrainQuick),
+	"fbm":            FBMTerrain{},
+	"diamond-square": DiamondSquareTerrain{},
+	"plates":         PlateTerrain{},
+	"tectonics":      TectonicTerrain{},
+}
+
+// TerrainGeneratorNames lists the keys of TERRAIN_GENERATORS in order
+func TerrainGeneratorNames() []string {
+	names := make([]string, 0, len(TERRAIN_GENERATORS))
+	for name := range TERRAIN_GENERATORS {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTerrainGenerator returns the generator registered under name
+func NewTerrainGenerator(name string) (TerrainGenerator, error) {
+	gen, ok := TERRAIN_GENERATORS[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown terrain generator %q, expected one of %v", name, TerrainGeneratorNames())
+	}
+	return gen, nil
+}
+
+// TerrainGeneratorName is the key of gen in TERRAIN_GENERATORS whatever its
+// settings, "particles" for nil and "" if it is not registered
+func TerrainGeneratorName(gen TerrainGenerator) string {
+	if gen == nil {
+		return "particles"
+	}
+	for _, name := range TerrainGeneratorNames() {
+		g := TERRAIN_GENERATORS[name]
+		if reflect.TypeOf(g) != reflect.TypeOf(gen) {
+			continue
+		}
+		// functions cannot be compared, their code can
+		if f, ok := gen.(TerrainFunc); ok && reflect.ValueOf(f).Pointer() != reflect.ValueOf(g).Pointer() {
+			continue
+		}
+		return name
+	}
+	return ""
+}
+
+// share of land of the generators working on real heights, unless LandPct is set
+const HEIGHTS_LAND_PCT int = 33
+
+func newHeights(cfg *Config) [][]float64 {
+	heights := make([][]float64, cfg.Height)
+	for y := range heights {
+		heights[y] = make([]float64, cfg.Width)
+	}
+	return heights
+}
+
+// heightsToTerrain floods the heights up to LandPct or HEIGHTS_LAND_PCT of land, then
+// turns them into the signed field: land goes from MaxVal-1 on the coast down
+// to 1 on the highest peak, sea from -MaxVal/2 on the coast to 1-MaxVal in the
+// abyss, deep enough that smoothing does not sink the coast below the peaks
+func heightsToTerrain(cfg *Config, heights [][]float64) [][]int {
+	sorted := make([]float64, 0, cfg.Surface())
+	for y := range heights {
+		sorted = append(sorted, heights[y]...)
+	}
+	sort.Float64s(sorted)
+	min, max := sorted[0], sorted[len(sorted)-1]
+	landPct := HEIGHTS_LAND_PCT
+	if cfg.LandPct > 0 {
+		landPct = cfg.LandPct
+	}
+	seaLevel := sorted[len(sorted)*(100-landPct)/100]
+
+	maxVal := cfg.MaxVal()
+	squares := NewSquares(cfg)
+	for y := range squares {
+		for x := range squares[y] {
+			h := heights[y][x]
+			if h > seaLevel {
+				squares[y][x] = 1 + int((max-h)/(max-seaLevel)*float64(maxVal-2))
+			} else if seaLevel > min {
+				squares[y][x] = -maxVal/2 - int((seaLevel-h)/(seaLevel-min)*float64(maxVal-1-maxVal/2))
+			} else {
+				squares[y][x] = -maxVal / 2
+			}
+		}
+	}
+	return squares
+}
@@ -0,0 +1,422 @@
+package lgc
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Projection maps a point in grid coordinates to output coordinates
+type Projection func(y, x float64) (px, py float64)
+
+// GridProjection keeps grid coordinates, x to the right and y downwards
+func GridProjection(y, x float64) (float64, float64) {
+	return x, y
+}
+
+// LonLatProjection spreads the grid over the whole globe, equirectangular
+func LonLatProjection(cfg *Config) Projection {
+	return func(y, x float64) (float64, float64) {
+		return x/float64(cfg.Width)*360 - 180, 90 - y/float64(cfg.Height)*180
+	}
+}
+
+type geoFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoGeometry            `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// a corner of a square, grid[y][x] has corners y..y+1, x..x+1
+type corner struct {
+	y, x int
+}
+
+type ring []corner
+
+// signed area, positive when clockwise on screen (y downwards)
+func (r ring) area() int {
+	a := 0
+	for i := range r {
+		j := (i + 1) % len(r)
+		a += r[i].x*r[j].y - r[j].x*r[i].y
+	}
+	return a
+}
+
+// even-odd rule, the point must not be on a grid line
+func (r ring) contains(y, x float64) bool {
+	in := false
+	for i := range r {
+		j := (i + 1) % len(r)
+		yi, xi, yj, xj := float64(r[i].y), float64(r[i].x), float64(r[j].y), float64(r[j].x)
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			in = !in
+		}
+	}
+	return in
+}
+
+// traceRings walks the edges of the squares for which in is true,
+// keeping them on the right; the map edges always count as a border,
+// so shapes crossing a wrapped edge are split there
+func traceRings(grid *Grid, in func(y, x int) bool) []ring {
+	isIn := func(y, x int) bool {
+		return y >= 0 && y < grid.Height && x >= 0 && x < grid.Width && in(y, x)
+	}
+	// outgoing border edges by starting corner
+	edges := make(map[corner][]corner)
+	for y := 0; y < grid.Height; y++ {
+		for x := 0; x < grid.Width; x++ {
+			if !isIn(y, x) {
+				continue
+			}
+			if !isIn(y-1, x) {
+				edges[corner{y, x}] = append(edges[corner{y, x}], corner{y, x + 1})
+			}
+			if !isIn(y, x+1) {
+				edges[corner{y, x + 1}] = append(edges[corner{y, x + 1}], corner{y + 1, x + 1})
+			}
+			if !isIn(y+1, x) {
+				edges[corner{y + 1, x + 1}] = append(edges[corner{y + 1, x + 1}], corner{y + 1, x})
+			}
+			if !isIn(y, x-1) {
+				edges[corner{y + 1, x}] = append(edges[corner{y + 1, x}], corner{y, x})
+			}
+		}
+	}
+
+	var rings []ring
+	for y := 0; y <= grid.Height; y++ {
+		for x := 0; x <= grid.Width; x++ {
+			start := corner{y, x}
+			for len(edges[start]) > 0 {
+				r := ring{start}
+				prev, cur := start, edges[start][0]
+				edges[start] = edges[start][1:]
+				for cur != start {
+					// where two squares touch by a corner, turn right to keep them apart
+					dy, dx := cur.y-prev.y, cur.x-prev.x
+					next := -1
+					for _, d := range [3][2]int{{dx, -dy}, {dy, dx}, {-dx, dy}} {
+						for i, c := range edges[cur] {
+							if c.y-cur.y == d[0] && c.x-cur.x == d[1] {
+								next = i
+								break
+							}
+						}
+						if next != -1 {
+							break
+						}
+					}
+					if next == -1 {
+						next = 0
+					}
+					c := edges[cur][next]
+					edges[cur] = append(edges[cur][:next], edges[cur][next+1:]...)
+					// only keep corners where the ring turns
+					if c.y-cur.y != dy || c.x-cur.x != dx {
+						r = append(r, cur)
+					}
+					prev, cur = cur, c
+				}
+				rings = append(rings, r)
+			}
+		}
+	}
+	return rings
+}
+
+// polygons groups traced rings into outer rings followed by their holes
+func polygons(rings []ring) [][]ring {
+	var polys [][]ring
+	var holes []ring
+	for _, r := range rings {
+		if r.area() > 0 {
+			polys = append(polys, []ring{r})
+		} else {
+			holes = append(holes, r)
+		}
+	}
+	for _, h := range holes {
+		// the square right of the first edge is part of the shape
+		dy, dx := Sign(h[1].y-h[0].y), Sign(h[1].x-h[0].x)
+		cy := float64(h[0].y) + float64(dy)/2 + float64(dx)/2
+		cx := float64(h[0].x) + float64(dx)/2 - float64(dy)/2
+		best := -1
+		for i, p := range polys {
+			if p[0].contains(cy, cx) && (best == -1 || p[0].area() < polys[best][0].area()) {
+				best = i
+			}
+		}
+		if best != -1 {
+			polys[best] = append(polys[best], h)
+		}
+	}
+	return polys
+}
+
+// project closes a ring and orients it counterclockwise for outer rings
+// and clockwise for holes, as GeoJSON wants
+func project(r ring, outer bool, proj Projection) [][2]float64 {
+	pts := make([][2]float64, 0, len(r)+1)
+	for _, c := range append(r, r[0]) {
+		px, py := proj(float64(c.y), float64(c.x))
+		pts = append(pts, [2]float64{px, py})
+	}
+	a := 0.0
+	for i := 0; i < len(pts)-1; i++ {
+		a += pts[i][0]*pts[i+1][1] - pts[i+1][0]*pts[i][1]
+	}
+	if (a > 0) != outer {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return pts
+}
+
+func colorHex(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// pathLines splits a path of squares where it crosses a wrapped edge, both
+// pieces going on to the edge, and leaves out what is left with a single point
+func pathLines(cfg *Config, ys, xs []int, proj Projection) [][][2]float64 {
+	var lines [][][2]float64
+	var line [][2]float64
+	point := func(y, x float64) [2]float64 {
+		px, py := proj(y+.5, x+.5)
+		return [2]float64{px, py}
+	}
+	for i := range ys {
+		if i > 0 && (2*Abs(ys[i]-ys[i-1]) > cfg.Height || 2*Abs(xs[i]-xs[i-1]) > cfg.Width) {
+			dy, dx := cfg.Offset(ys[i-1], xs[i-1], ys[i], xs[i])
+			line = append(line, point(float64(ys[i-1])+float64(dy)/2, float64(xs[i-1])+float64(dx)/2))
+			if len(line) >= 2 {
+				lines = append(lines, line)
+			}
+			line = [][2]float64{point(float64(ys[i])-float64(dy)/2, float64(xs[i])-float64(dx)/2)}
+		}
+		line = append(line, point(float64(ys[i]), float64(xs[i])))
+	}
+	if len(line) >= 2 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// riverLines splits the river path where it crosses a wrapped edge
+func riverLines(cfg *Config, r *River, proj Projection) [][][2]float64 {
+	ys, xs := make([]int, len(r.pathStack)), make([]int, len(r.pathStack))
+	for n, i := range r.pathStack {
+		ys[n], xs[n] = r.y[i], r.x[i]
+	}
+	return pathLines(cfg, ys, xs, proj)
+}
+
+// roadLines goes from the road's first node to its last through its squares
+func (world *World) roadLines(r *Road, proj Projection) [][][2]float64 {
+	from, to := world.RoadNodes[r.From], world.RoadNodes[r.To]
+	ys := append(append([]int{from.Y}, r.Y...), to.Y)
+	xs := append(append([]int{from.X}, r.X...), to.X)
+	return pathLines(world.Config, ys, xs, proj)
+}
+
+// PrintGeoJSON writes countries as polygons, cities as points and rivers as lines
+func PrintGeoJSON(world *World, w io.Writer, proj Projection) error {
+	grid := world.Grid
+	features := []geoFeature{}
+
+	if world.Countries != nil {
+		for ic, country := range world.Countries.countries {
+			var coords [][][][2]float64
+			for _, poly := range polygons(traceRings(grid, func(y, x int) bool {
+				return grid.Squares[y][x].CountryIndex == ic
+			})) {
+				var rings [][][2]float64
+				for i, r := range poly {
+					rings = append(rings, project(r, i == 0, proj))
+				}
+				coords = append(coords, rings)
+			}
+			if len(coords) == 0 {
+				continue
+			}
+			geometry := geoGeometry{Type: "MultiPolygon", Coordinates: coords}
+			if len(coords) == 1 {
+				geometry = geoGeometry{Type: "Polygon", Coordinates: coords[0]}
+			}
+			features = append(features, geoFeature{
+				Type:     "Feature",
+				Geometry: geometry,
+				Properties: map[string]interface{}{
+					"kind":    "country",
+					"index":   ic,
+					"color":   colorHex(country.Color),
+					"cities":  len(country.Cities),
+					"surface": country.Surface(),
+				},
+			})
+		}
+	}
+
+	lakes := polygons(traceRings(grid, func(y, x int) bool {
+		return grid.Squares[y][x].Terrain == TERRAIN_LAKE
+	}))
+	for i, poly := range lakes {
+		var rings [][][2]float64
+		for j, r := range poly {
+			rings = append(rings, project(r, j == 0, proj))
+		}
+		features = append(features, geoFeature{
+			Type:     "Feature",
+			Geometry: geoGeometry{Type: "Polygon", Coordinates: rings},
+			Properties: map[string]interface{}{
+				"kind":  "lake",
+				"index": i,
+			},
+		})
+	}
+
+	for i, lm := range world.Landmasses {
+		px, py := proj(float64(lm.Y)+.5, float64(lm.X)+.5)
+		props := map[string]interface{}{
+			"kind":    "landmass",
+			"index":   i,
+			"type":    lm.KindName(),
+			"name":    lm.Name,
+			"surface": lm.Size,
+		}
+		if lm.Archipelago != -1 {
+			props["archipelago"] = world.Archipelagos[lm.Archipelago].Name
+		}
+		features = append(features, geoFeature{
+			Type:       "Feature",
+			Geometry:   geoGeometry{Type: "Point", Coordinates: [2]float64{px, py}},
+			Properties: props,
+		})
+	}
+
+	for i, city := range world.Cities {
+		px, py := proj(float64(city.CenterY)+.5, float64(city.CenterX)+.5)
+		features = append(features, geoFeature{
+			Type:     "Feature",
+			Geometry: geoGeometry{Type: "Point", Coordinates: [2]float64{px, py}},
+			Properties: map[string]interface{}{
+				"kind":       "city",
+				"index":      i,
+				"population": city.Population,
+				"tier":       city.TierName(),
+				"country":    grid.Squares[city.CenterY][city.CenterX].CountryIndex,
+			},
+		})
+	}
+
+	for i, river := range world.Rivers {
+		lines := riverLines(world.Config, river, proj)
+		if len(lines) == 0 {
+			// a single square
+			continue
+		}
+		geometry := geoGeometry{Type: "MultiLineString", Coordinates: lines}
+		if len(lines) == 1 {
+			geometry = geoGeometry{Type: "LineString", Coordinates: lines[0]}
+		}
+		features = append(features, geoFeature{
+			Type:     "Feature",
+			Geometry: geometry,
+			Properties: map[string]interface{}{
+				"kind":   "river",
+				"index":  i,
+				"length": river.Len(),
+				"level":  river.Level,
+			},
+		})
+	}
+
+	for i, road := range world.Roads {
+		lines := world.roadLines(road, proj)
+		if len(lines) == 0 {
+			// a single square
+			continue
+		}
+		geometry := geoGeometry{Type: "MultiLineString", Coordinates: lines}
+		if len(lines) == 1 {
+			geometry = geoGeometry{Type: "LineString", Coordinates: lines[0]}
+		}
+		bridges := 0
+		for j := range road.Y {
+			if grid.Squares[road.Y[j]][road.X[j]].IsBridge() {
+				bridges++
+			}
+		}
+		props := map[string]interface{}{
+			"kind":    "road",
+			"index":   i,
+			"from":    road.From,
+			"to":      road.To,
+			"length":  len(road.Y),
+			"bridges": bridges,
+		}
+		if c := world.RoadNodes[road.From].City; c != -1 {
+			props["from_city"] = c
+		}
+		if c := world.RoadNodes[road.To].City; c != -1 {
+			props["to_city"] = c
+		}
+		features = append(features, geoFeature{
+			Type:       "Feature",
+			Geometry:   geometry,
+			Properties: props,
+		})
+	}
+
+	for i, port := range world.Ports {
+		px, py := proj(float64(port.Y)+.5, float64(port.X)+.5)
+		features = append(features, geoFeature{
+			Type:     "Feature",
+			Geometry: geoGeometry{Type: "Point", Coordinates: [2]float64{px, py}},
+			Properties: map[string]interface{}{
+				"kind":  "port",
+				"index": i,
+				"city":  port.City,
+			},
+		})
+	}
+
+	for i, lane := range world.SeaLanes {
+		lines := pathLines(world.Config, lane.Y, lane.X, proj)
+		if len(lines) == 0 {
+			// a single square
+			continue
+		}
+		geometry := geoGeometry{Type: "MultiLineString", Coordinates: lines}
+		if len(lines) == 1 {
+			geometry = geoGeometry{Type: "LineString", Coordinates: lines[0]}
+		}
+		features = append(features, geoFeature{
+			Type:     "Feature",
+			Geometry: geometry,
+			Properties: map[string]interface{}{
+				"kind":   "sea-lane",
+				"index":  i,
+				"from":   lane.From,
+				"to":     lane.To,
+				"length": len(lane.Y),
+			},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": features,
+	})
+}
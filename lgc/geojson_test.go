@@ -0,0 +1,170 @@
+package lgc
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// twice the signed area of a closed ring, positive when counterclockwise
+// with y upwards
+func ringArea(pts [][2]float64) float64 {
+	a := 0.0
+	for i := 0; i < len(pts)-1; i++ {
+		a += pts[i][0]*pts[i+1][1] - pts[i+1][0]*pts[i][1]
+	}
+	return a
+}
+
+// checkRing wants a closed ring of at least 4 positions, counterclockwise
+// if outer and clockwise for a hole, as RFC 7946 has it
+func checkRing(t *testing.T, pts [][2]float64, outer bool) {
+	t.Helper()
+	if len(pts) < 4 {
+		t.Errorf("ring of %v positions, expected at least 4", len(pts))
+		return
+	}
+	if pts[0] != pts[len(pts)-1] {
+		t.Errorf("ring from %v to %v is not closed", pts[0], pts[len(pts)-1])
+	}
+	if a := ringArea(pts); (a > 0) != outer {
+		t.Errorf("ring of area %v, outer %v, is the wrong way round", a/2, outer)
+	}
+}
+
+func TestPolygonRings(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		shape []string
+		// holes of each polygon, in the order they are traced
+		holes []int
+	}{
+		{"square", []string{
+			"....",
+			".#..",
+			"....",
+		}, []int{0}},
+		{"two islands", []string{
+			"##...",
+			"##..#",
+			".....",
+		}, []int{0, 0}},
+		{"corners touching", []string{
+			"#.",
+			".#",
+		}, []int{0, 0}},
+		{"lake", []string{
+			".....",
+			".###.",
+			".#.#.",
+			".###.",
+			".....",
+		}, []int{1}},
+		{"island in a lake", []string{
+			"#####",
+			"#...#",
+			"#.#.#",
+			"#...#",
+			"#####",
+		}, []int{1, 0}},
+		{"two lakes", []string{
+			"#####",
+			"#.#.#",
+			"#####",
+		}, []int{2}},
+		{"on the edges", []string{
+			"#..#",
+			"....",
+			"#..#",
+		}, []int{0, 0, 0, 0}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig(len(tc.shape[0]), len(tc.shape))
+			grid := NewGrid(cfg)
+			polys := polygons(traceRings(grid, func(y, x int) bool { return tc.shape[y][x] == '#' }))
+			if len(polys) != len(tc.holes) {
+				t.Fatalf("%v polygons, expected %v", len(polys), len(tc.holes))
+			}
+			for i, poly := range polys {
+				if len(poly)-1 != tc.holes[i] {
+					t.Errorf("polygon %v has %v holes, expected %v", i, len(poly)-1, tc.holes[i])
+				}
+				for _, proj := range []Projection{GridProjection, LonLatProjection(cfg)} {
+					for j, r := range poly {
+						checkRing(t, project(r, j == 0, proj), j == 0)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGeoJSONGeometries(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		connectY, connectX bool
+	}{
+		{"flat", false, false},
+		{"torus", true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testConfig(7, "plates")
+			cfg.ConnectY, cfg.ConnectX = tc.connectY, tc.connectX
+			world := testWorld(t, cfg)
+			var buf bytes.Buffer
+			if err := PrintGeoJSON(world, &buf, LonLatProjection(cfg)); err != nil {
+				t.Fatal(err)
+			}
+			var fc struct {
+				Features []struct {
+					Geometry struct {
+						Type        string
+						Coordinates json.RawMessage
+					}
+				}
+			}
+			if err := json.Unmarshal(buf.Bytes(), &fc); err != nil {
+				t.Fatal(err)
+			}
+			counts := make(map[string]int)
+			for _, f := range fc.Features {
+				g := f.Geometry
+				counts[g.Type]++
+				var polys [][][][2]float64
+				var lines [][][2]float64
+				var err error
+				switch g.Type {
+				case "Polygon":
+					polys = make([][][][2]float64, 1)
+					err = json.Unmarshal(g.Coordinates, &polys[0])
+				case "MultiPolygon":
+					err = json.Unmarshal(g.Coordinates, &polys)
+				case "LineString":
+					lines = make([][][2]float64, 1)
+					err = json.Unmarshal(g.Coordinates, &lines[0])
+				case "MultiLineString":
+					err = json.Unmarshal(g.Coordinates, &lines)
+					if err == nil && len(lines) == 0 {
+						t.Error("MultiLineString without lines")
+					}
+				}
+				if err != nil {
+					t.Fatalf("%v: %v", g.Type, err)
+				}
+				for _, poly := range polys {
+					for j, r := range poly {
+						checkRing(t, r, j == 0)
+					}
+				}
+				for _, l := range lines {
+					if len(l) < 2 {
+						t.Errorf("%v with a line of %v positions", g.Type, len(l))
+					}
+				}
+			}
+			if counts["Polygon"]+counts["MultiPolygon"] == 0 || counts["LineString"]+counts["MultiLineString"] == 0 {
+				t.Errorf("expected polygons and lines, got %v", counts)
+			}
+		})
+	}
+}
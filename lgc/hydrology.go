@@ -0,0 +1,200 @@
+package lgc
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+)
+
+// how rivers are traced
+const (
+	// random walkers from mountains to the sea
+	RIVER_MODE_WALK = iota
+	// drainage basins from the flow accumulated over the whole land
+	RIVER_MODE_FLOW
+)
+
+// squareItem is a square waiting in a squareQueue, by increasing priority
+type squareItem struct {
+	y, x     int
+	priority float64
+}
+
+// squareQueue is a container/heap of squares, lowest priority first
+type squareQueue []squareItem
+
+func (q squareQueue) Len() int            { return len(q) }
+func (q squareQueue) Less(i, j int) bool  { return q[i].priority < q[j].priority }
+func (q squareQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *squareQueue) Push(v interface{}) { *q = append(*q, v.(squareItem)) }
+func (q *squareQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// FLOOD_EPSILON keeps filled depressions sloping towards their outlet
+const FLOOD_EPSILON float64 = 1e-3
+
+// FillDepressions raises every land square that has no way down to the sea
+// up to the level of its outlet, with a slight slope so water still flows;
+// sea squares are at -1
+func FillDepressions(grid *Grid) [][]float64 {
+	filled := elevations(grid)
+	done := make([][]bool, grid.Height)
+	for y := range done {
+		done[y] = make([]bool, grid.Width)
+	}
+	q := &squareQueue{}
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if st.Terrain == TERRAIN_SEA {
+				filled[y][x] = -1
+				done[y][x] = true
+				heap.Push(q, squareItem{y, x, -1})
+			}
+		}
+	}
+	// a world without sea drains through its lowest square
+	if q.Len() == 0 {
+		ly, lx := 0, 0
+		for y := range filled {
+			for x := range filled[y] {
+				if filled[y][x] < filled[ly][lx] {
+					ly, lx = y, x
+				}
+			}
+		}
+		done[ly][lx] = true
+		heap.Push(q, squareItem{ly, lx, filled[ly][lx]})
+	}
+	for q.Len() > 0 {
+		item := heap.Pop(q).(squareItem)
+		for _, dir := range DIR_NEXT {
+			ny, nx, ok := grid.Neighbour(item.y, item.x, dir)
+			if !ok || done[ny][nx] {
+				continue
+			}
+			done[ny][nx] = true
+			if filled[ny][nx] <= item.priority {
+				filled[ny][nx] = item.priority + FLOOD_EPSILON
+			}
+			heap.Push(q, squareItem{ny, nx, filled[ny][nx]})
+		}
+	}
+	return filled
+}
+
+// AddFlowRivers gives every land square the number of land squares draining
+// through it, then turns the RiverPct of land with the most flow into rivers
+func (world *World) AddFlowRivers(ctx context.Context) error {
+	grid := world.Grid
+	filled := FillDepressions(grid)
+
+	// flow direction, to the lowest of the four neighbours
+	type square struct{ y, x int }
+	next := make([][]square, grid.Height)
+	var land []square
+	for y := range grid.Squares {
+		next[y] = make([]square, grid.Width)
+		for x, st := range grid.Squares[y] {
+			if st.Terrain == TERRAIN_SEA {
+				continue
+			}
+			land = append(land, square{y, x})
+			next[y][x] = square{y, x}
+			for i, dir := range DIR_NEXT {
+				ny, nx, ok := grid.Neighbour(y, x, dir)
+				if ok && filled[ny][nx] < filled[next[y][x].y][next[y][x].x] {
+					next[y][x] = square{ny, nx}
+					st.FlowTo = i
+				}
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// accumulation, from the highest squares down
+	sort.SliceStable(land, func(i, j int) bool {
+		return filled[land[i].y][land[i].x] > filled[land[j].y][land[j].x]
+	})
+	for _, s := range land {
+		grid.Squares[s.y][s.x].Flow++
+		if n := next[s.y][s.x]; n != s && grid.Squares[n.y][n.x].Terrain != TERRAIN_SEA {
+			grid.Squares[n.y][n.x].Flow += grid.Squares[s.y][s.x].Flow
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// rivers where the flow is above the threshold
+	nbRiver := len(land) * grid.RiverPct / 100
+	if nbRiver == 0 {
+		world.logf("0 rivers: 0")
+		return nil
+	}
+	flows := make([]int, len(land))
+	for i, s := range land {
+		flows[i] = grid.Squares[s.y][s.x].Flow
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(flows)))
+	threshold := flows[nbRiver-1]
+	if threshold < 2 {
+		threshold = 2
+	}
+	isRiver := func(s square) bool {
+		st := grid.Squares[s.y][s.x]
+		return (st.Terrain == TERRAIN_LAND || st.Terrain == TERRAIN_MOUNTAIN) && st.Flow >= threshold
+	}
+
+	// each source starts a river that ends at the sea or where it joins
+	// a river already traced, longest first so they become the main streams
+	fed := make(map[square]bool)
+	for _, s := range land {
+		if isRiver(s) {
+			fed[next[s.y][s.x]] = true
+		}
+	}
+	var sources []square
+	length := make(map[square]int)
+	for _, s := range land {
+		if !isRiver(s) || fed[s] {
+			continue
+		}
+		sources = append(sources, s)
+		for c := s; isRiver(c) && next[c.y][c.x] != c; c = next[c.y][c.x] {
+			length[s]++
+		}
+	}
+	sort.SliceStable(sources, func(i, j int) bool {
+		return length[sources[i]] > length[sources[j]]
+	})
+
+	var riverSurface int
+	for i, s := range sources {
+		world.report(STAGE_RIVERS, i, len(sources))
+		st := grid.Squares[s.y][s.x]
+		river := &River{
+			y:         []int{s.y},
+			x:         []int{s.x},
+			pathStack: []int{0},
+			Level:     st.Val,
+		}
+		st.Feature = FEATURE_RIVER
+		for c := next[s.y][s.x]; isRiver(c) && grid.Squares[c.y][c.x].Feature != FEATURE_RIVER; c = next[c.y][c.x] {
+			grid.Squares[c.y][c.x].Feature = FEATURE_RIVER
+			river.Move(c.y, c.x)
+			if next[c.y][c.x] == c {
+				break
+			}
+		}
+		riverSurface += river.Len()
+		world.Rivers = append(world.Rivers, river)
+	}
+	world.logf("%v rivers: %v, flow threshold %v", len(world.Rivers), riverSurface, threshold)
+	return nil
+}
@@ -0,0 +1,91 @@
+package lgc
+
+import "testing"
+
+// testGrid makes land of the given heights, 0 to 9, and sea for '.'
+func testGrid(cfg *Config, rows []string) *Grid {
+	grid := NewGrid(cfg)
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '.' {
+				grid.Squares[y][x] = NewSquareTerrain(cfg, 0)
+				continue
+			}
+			// elevations go up as values go down on land
+			grid.Squares[y][x] = NewSquareTerrain(cfg, 255-10*int(c-'0'))
+			grid.Squares[y][x].Terrain = TERRAIN_LAND
+		}
+	}
+	return grid
+}
+
+// every land square has a way down to the sea once depressions are filled
+func TestFillDepressions(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		connectY, connectX bool
+		rows               []string
+	}{
+		{"bowl", false, false, []string{
+			".....",
+			".999.",
+			".919.",
+			".999.",
+			".....",
+		}},
+		{"nested basins", false, false, []string{
+			"........",
+			".999999.",
+			".915859.",
+			".925349.",
+			".999999.",
+			"........",
+		}},
+		{"pit against the side", false, false, []string{
+			"1999.",
+			"9999.",
+			"9999.",
+		}},
+		{"pit across the side", false, true, []string{
+			"1999.",
+			"9999.",
+			"9999.",
+		}},
+		{"no sea", true, true, []string{
+			"5555",
+			"5195",
+			"5555",
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig(len(tc.rows[0]), len(tc.rows))
+			cfg.ConnectY, cfg.ConnectX = tc.connectY, tc.connectX
+			grid := testGrid(cfg, tc.rows)
+			filled := FillDepressions(grid)
+
+			// with no sea, water leaves through the lowest square
+			drain := func(y, x int) bool { return grid.Squares[y][x].Terrain == TERRAIN_SEA }
+			if grid.Count(func(st *SquareTerrain) bool { return st.Terrain == TERRAIN_SEA }) == 0 {
+				drain = func(y, x int) bool { return tc.rows[y][x] == '1' }
+			}
+			for y := range filled {
+				for x := range filled[y] {
+					cy, cx := y, x
+					for steps := 0; !drain(cy, cx); steps++ {
+						ly, lx := cy, cx
+						for _, dir := range DIR_NEXT {
+							ny, nx, ok := grid.Neighbour(cy, cx, dir)
+							if ok && filled[ny][nx] < filled[ly][lx] {
+								ly, lx = ny, nx
+							}
+						}
+						if (ly == cy && lx == cx) || steps > cfg.Surface() {
+							t.Fatalf("water from %v,%v is stuck at %v,%v", y, x, cy, cx)
+						}
+						cy, cx = ly, lx
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package lgc
+
+import (
+	"context"
+	"image/color"
+	"math/rand"
+)
+
+const (
+	// a basin this deep on the 0..255 land scale holds a lake
+	LAKE_MIN_DEPTH float64 = 32
+	// no lake covers more than this share of the map, in percent, larger
+	// seas are part of the ocean and larger basins are low plains
+	LAKE_MAX_PCT int = 2
+)
+
+// IsWater is true for the sea and lakes
+func (st *SquareTerrain) IsWater() bool {
+	return st.Terrain == TERRAIN_SEA || st.Terrain == TERRAIN_LAKE
+}
+
+// components labels the 4-connected groups of squares for which in is true,
+// and returns the size of each label; other squares are -1
+func components(grid *Grid, in func(y, x int) bool) ([][]int, []int) {
+	label := make([][]int, grid.Height)
+	for y := range label {
+		label[y] = make([]int, grid.Width)
+		for x := range label[y] {
+			label[y][x] = -1
+		}
+	}
+	var sizes []int
+	for y := range label {
+		for x := range label[y] {
+			if label[y][x] != -1 || !in(y, x) {
+				continue
+			}
+			l := len(sizes)
+			sizes = append(sizes, 0)
+			label[y][x] = l
+			stack := [][2]int{{y, x}}
+			for len(stack) > 0 {
+				s := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				sizes[l]++
+				for _, dir := range DIR_NEXT {
+					ny, nx, ok := grid.Neighbour(s[0], s[1], dir)
+					if ok && label[ny][nx] == -1 && in(ny, nx) {
+						label[ny][nx] = l
+						stack = append(stack, [2]int{ny, nx})
+					}
+				}
+			}
+		}
+	}
+	return label, sizes
+}
+
+// AddLakes turns small seas cut off from the ocean, and land basins with
+// no way out deeper than LAKE_MIN_DEPTH, into lakes
+func (world *World) AddLakes(ctx context.Context) error {
+	grid := world.Grid
+
+	// inland seas
+	seas, sizes := components(grid, func(y, x int) bool {
+		return grid.Squares[y][x].Terrain == TERRAIN_SEA
+	})
+	ocean := -1
+	for l, size := range sizes {
+		if ocean == -1 || size > sizes[ocean] {
+			ocean = l
+		}
+	}
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if l := seas[y][x]; l != -1 && l != ocean && sizes[l]*100 < grid.Surface()*LAKE_MAX_PCT {
+				st.Terrain = TERRAIN_LAKE
+				world.NbSea--
+				world.NbLake++
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// basins, filled up to where they spill
+	filled := FillDepressions(grid)
+	el := elevations(grid)
+	basins, basinSizes := components(grid, func(y, x int) bool {
+		return grid.Squares[y][x].Terrain == TERRAIN_LAND && filled[y][x]-el[y][x] >= 1
+	})
+	deep := make(map[int]bool)
+	for y := range basins {
+		for x, l := range basins[y] {
+			if l != -1 && filled[y][x]-el[y][x] >= LAKE_MIN_DEPTH && basinSizes[l]*100 < grid.Surface()*LAKE_MAX_PCT {
+				deep[l] = true
+			}
+		}
+	}
+	for y := range basins {
+		for x, l := range basins[y] {
+			if deep[l] {
+				st := grid.Squares[y][x]
+				st.Terrain = TERRAIN_LAKE
+				// lakes keep their depth, as the sea does
+				st.Val = int(filled[y][x] - el[y][x])
+				world.NbLand--
+				world.NbLake++
+			}
+		}
+	}
+	world.logf("lakes: %v", world.NbLake)
+	return nil
+}
+
+func (st *SquareTerrain) DrawLake(rng *rand.Rand) error {
+	ripple := color.RGBA{150, 220, 235, 255}
+	colors := map[byte]color.Color{
+		'~': ripple,
+		'.': color.Transparent,
+	}
+	shapes := [...]string{
+		"..........~~....~..~.........................~~...~..~..........",
+		".........................~~.....~..~............................",
+		"................................................................",
+	}
+	return st.Draw(shapes[rng.Intn(len(shapes))], colors)
+}
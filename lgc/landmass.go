@@ -0,0 +1,233 @@
+package lgc
+
+import (
+	"context"
+	"sort"
+)
+
+// kinds of landmasses
+const (
+	LANDMASS_CONTINENT = iota
+	LANDMASS_ISLAND
+	LANDMASS_ISLET
+)
+
+var LANDMASS_KIND_NAMES = [...]string{
+	LANDMASS_CONTINENT: "continent",
+	LANDMASS_ISLAND:    "island",
+	LANDMASS_ISLET:     "islet",
+}
+
+const (
+	// a landmass with this share of the land, in percent, is a continent
+	LANDMASS_CONTINENT_PCT = 10
+	// up to this many squares, a landmass is an islet
+	LANDMASS_ISLET_SIZE = 4
+	// an archipelago gathers at least this many islands and islets, each
+	// at most Magic()/6 squares of sea away from another
+	ARCHIPELAGO_MIN = 3
+)
+
+// Landmass is a group of land squares connected by their sides
+type Landmass struct {
+	Kind int
+	Size int
+	Name string
+	// the square farthest from the sea, where a label goes
+	Y, X int
+	// index in World.Archipelagos, -1 if none
+	Archipelago int
+}
+
+// Archipelago is a cluster of islands and islets
+type Archipelago struct {
+	Name string
+	// indexes in World.Landmasses
+	Landmasses []int
+}
+
+func (lm *Landmass) KindName() string {
+	return LANDMASS_KIND_NAMES[lm.Kind]
+}
+
+// LandmassesOfKind lists the landmasses of one kind, largest first, so
+// LandmassesOfKind(LANDMASS_CONTINENT)[2] is the third largest continent
+func (world *World) LandmassesOfKind(kind int) []*Landmass {
+	var lms []*Landmass
+	for _, lm := range world.Landmasses {
+		if lm.Kind == kind {
+			lms = append(lms, lm)
+		}
+	}
+	return lms
+}
+
+// distances counts the squares from every square to the nearest one for
+// which from is true, those being at 0 and unreachable squares at -1
+func distances(grid *Grid, from func(y, x int) bool) [][]int {
+	dist := make([][]int, grid.Height)
+	var queue [][2]int
+	for y := range grid.Squares {
+		dist[y] = make([]int, grid.Width)
+		for x := range dist[y] {
+			dist[y][x] = -1
+			if from(y, x) {
+				dist[y][x] = 0
+				queue = append(queue, [2]int{y, x})
+			}
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, dir := range DIR_NEXT {
+			ny, nx, ok := grid.Neighbour(s[0], s[1], dir)
+			if ok && dist[ny][nx] == -1 {
+				dist[ny][nx] = dist[s[0]][s[1]] + 1
+				queue = append(queue, [2]int{ny, nx})
+			}
+		}
+	}
+	return dist
+}
+
+func isLand(st *SquareTerrain) bool {
+	return st.Terrain == TERRAIN_LAND || st.Terrain == TERRAIN_MOUNTAIN
+}
+
+// AddLandmasses labels every continent, island and islet, largest first,
+// groups nearby islands into archipelagos and names them all
+func (world *World) AddLandmasses(ctx context.Context) error {
+	grid := world.Grid
+	// the sides of the map that are not connected become its border
+	inMass := func(y, x int) bool {
+		return isLand(grid.Squares[y][x]) && !grid.OnBorder(y, x)
+	}
+	labels, sizes := components(grid, inMass)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// ranks by size, the first label found wins ties
+	order := make([]int, len(sizes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return sizes[order[i]] > sizes[order[j]]
+	})
+	rank := make([]int, len(sizes))
+	world.Landmasses = make([]*Landmass, len(sizes))
+	for r, l := range order {
+		rank[l] = r
+		lm := &Landmass{
+			Kind:        LANDMASS_ISLAND,
+			Size:        sizes[l],
+			Y:           -1,
+			Archipelago: -1,
+		}
+		switch {
+		case lm.Size*100 >= world.NbLand*LANDMASS_CONTINENT_PCT:
+			lm.Kind = LANDMASS_CONTINENT
+		case lm.Size <= LANDMASS_ISLET_SIZE:
+			lm.Kind = LANDMASS_ISLET
+		}
+		world.Landmasses[r] = lm
+	}
+
+	inland := distances(grid, func(y, x int) bool { return !inMass(y, x) })
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			st.LandmassIndex = -1
+			if labels[y][x] == -1 {
+				continue
+			}
+			st.LandmassIndex = rank[labels[y][x]]
+			lm := world.Landmasses[st.LandmassIndex]
+			if lm.Y == -1 || inland[y][x] > inland[lm.Y][lm.X] {
+				lm.Y, lm.X = y, x
+			}
+		}
+	}
+
+	// archipelagos, by spreading each island over the sea up to gap squares
+	// and joining the islands that meet
+	gap := grid.Magic() / 6
+	parent := make([]int, len(world.Landmasses))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	reach := make([][]int, grid.Height)
+	dist := make([][]int, grid.Height)
+	var queue [][2]int
+	for y := range grid.Squares {
+		reach[y] = make([]int, grid.Width)
+		dist[y] = make([]int, grid.Width)
+		for x, st := range grid.Squares[y] {
+			reach[y][x] = -1
+			if st.LandmassIndex != -1 && world.Landmasses[st.LandmassIndex].Kind != LANDMASS_CONTINENT {
+				reach[y][x] = st.LandmassIndex
+				queue = append(queue, [2]int{y, x})
+			}
+		}
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for _, dir := range DIR_NEXT {
+			ny, nx, ok := grid.Neighbour(s[0], s[1], dir)
+			r := reach[ny][nx]
+			if !ok || r == reach[s[0]][s[1]] {
+				continue
+			}
+			if r != -1 {
+				// two islands meet, with that many squares of sea between them
+				if dist[s[0]][s[1]]+dist[ny][nx] <= gap {
+					parent[find(r)] = find(reach[s[0]][s[1]])
+				}
+				continue
+			}
+			if isLand(grid.Squares[ny][nx]) || dist[s[0]][s[1]] >= gap {
+				// a continent, or too far out
+				continue
+			}
+			reach[ny][nx], dist[ny][nx] = reach[s[0]][s[1]], dist[s[0]][s[1]]+1
+			queue = append(queue, [2]int{ny, nx})
+		}
+	}
+	clusters := make(map[int][]int)
+	for i, lm := range world.Landmasses {
+		if lm.Kind != LANDMASS_CONTINENT {
+			clusters[find(i)] = append(clusters[find(i)], i)
+		}
+	}
+	world.Archipelagos = nil
+	for i := range world.Landmasses {
+		if members := clusters[i]; len(members) >= ARCHIPELAGO_MIN {
+			for _, m := range members {
+				world.Landmasses[m].Archipelago = len(world.Archipelagos)
+			}
+			world.Archipelagos = append(world.Archipelagos, &Archipelago{Landmasses: members})
+		}
+	}
+
+	for _, lm := range world.Landmasses {
+		if lm.Kind != LANDMASS_ISLET {
+			lm.Name = RandomName(world.rng)
+		}
+	}
+	for _, a := range world.Archipelagos {
+		a.Name = RandomName(world.rng)
+	}
+	world.logf("landmasses: %v continents, %v islands, %v islets, %v archipelagos",
+		len(world.LandmassesOfKind(LANDMASS_CONTINENT)), len(world.LandmassesOfKind(LANDMASS_ISLAND)),
+		len(world.LandmassesOfKind(LANDMASS_ISLET)), len(world.Archipelagos))
+	return nil
+}
@@ -0,0 +1,26 @@
+package lgc
+
+import (
+	"math/rand"
+	"strings"
+)
+
+var (
+	NAME_ONSETS = [...]string{"", "b", "br", "c", "d", "dr", "f", "g", "gr", "h", "k", "l", "m", "n", "p", "r", "s", "st", "t", "th", "v", "z"}
+	NAME_VOWELS = [...]string{"a", "e", "i", "o", "u", "ae", "ia", "ou", "y"}
+	NAME_CODAS  = [...]string{"", "", "", "l", "n", "r", "s", "th", "nd", "rn"}
+)
+
+// RandomName strings two or three syllables together
+func RandomName(rng *rand.Rand) string {
+	var b strings.Builder
+	for i := 2 + rng.Intn(2); i > 0; i-- {
+		b.WriteString(NAME_ONSETS[rng.Intn(len(NAME_ONSETS))])
+		b.WriteString(NAME_VOWELS[rng.Intn(len(NAME_VOWELS))])
+		if i == 1 || rng.Intn(3) == 0 {
+			b.WriteString(NAME_CODAS[rng.Intn(len(NAME_CODAS))])
+		}
+	}
+	name := b.String()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,231 @@
+package lgc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+)
+
+// perlin is one octave of gradient noise with cellsY x cellsX lattice cells
+// over the whole map, tiling along connected edges
+type perlin struct {
+	cfg            *Config
+	cellsY, cellsX int
+	grads          [][][2]float64
+}
+
+func newPerlin(cfg *Config, rng *rand.Rand, cellsY, cellsX int) *perlin {
+	p := &perlin{
+		cfg:    cfg,
+		cellsY: cellsY,
+		cellsX: cellsX,
+		grads:  make([][][2]float64, cellsY+1),
+	}
+	for y := range p.grads {
+		p.grads[y] = make([][2]float64, cellsX+1)
+		for x := range p.grads[y] {
+			a := rng.Float64() * 2 * math.Pi
+			p.grads[y][x] = [2]float64{math.Sin(a), math.Cos(a)}
+		}
+	}
+	if cfg.ConnectY {
+		copy(p.grads[cellsY], p.grads[0])
+	}
+	if cfg.ConnectX {
+		for y := range p.grads {
+			p.grads[y][cellsX] = p.grads[y][0]
+		}
+	}
+	return p
+}
+
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+// at is about within -1..1
+func (p *perlin) at(y, x int) float64 {
+	fy := (float64(y) + .5) * float64(p.cellsY) / float64(p.cfg.Height)
+	fx := (float64(x) + .5) * float64(p.cellsX) / float64(p.cfg.Width)
+	iy, ix := int(fy), int(fx)
+	ty, tx := fy-float64(iy), fx-float64(ix)
+	dot := func(cy, cx int) float64 {
+		g := p.grads[iy+cy][ix+cx]
+		return g[0]*(ty-float64(cy)) + g[1]*(tx-float64(cx))
+	}
+	sy, sx := fade(ty), fade(tx)
+	top := dot(0, 0) + sx*(dot(0, 1)-dot(0, 0))
+	bottom := dot(1, 0) + sx*(dot(1, 1)-dot(1, 0))
+	return (top + sy*(bottom-top)) * math.Sqrt2
+}
+
+// fbm sums octaves of noise, each with twice the cells and persistence times the amplitude
+func fbm(ctx context.Context, cfg *Config, rng *rand.Rand, octaves int, persistence float64) ([][]float64, error) {
+	heights := newHeights(cfg)
+	// about one cell per continent on the first octave
+	cellsY, cellsX := cfg.Height/cfg.Magic(), cfg.Width/cfg.Magic()
+	if cellsY < 2 {
+		cellsY = 2
+	}
+	if cellsX < 2 {
+		cellsX = 2
+	}
+	amp := 1.0
+	for o := 0; o < octaves && cellsY <= cfg.Height && cellsX <= cfg.Width; o++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cfg.report(STAGE_TERRAIN, o, octaves)
+		p := newPerlin(cfg, rng, cellsY, cellsX)
+		for y := range heights {
+			for x := range heights[y] {
+				heights[y][x] += amp * p.at(y, x)
+			}
+		}
+		cellsY, cellsX = cellsY*2, cellsX*2
+		amp *= persistence
+	}
+	return heights, nil
+}
+
+// FBMTerrain is fractal Brownian motion over Perlin noise
+type FBMTerrain struct {
+	// 6 and .5 if zero
+	Octaves     int
+	Persistence float64
+}
+
+func (g FBMTerrain) GenerateTerrain(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error) {
+	if g.Octaves == 0 {
+		g.Octaves = 6
+	}
+	if g.Persistence == 0 {
+		g.Persistence = .5
+	}
+	cfg.logf("fbm terrain %vx%v, %v octaves", cfg.Width, cfg.Height, g.Octaves)
+	heights, err := fbm(ctx, cfg, rng, g.Octaves, g.Persistence)
+	if err != nil {
+		return nil, err
+	}
+	cfg.report(STAGE_TERRAIN, 1, 1)
+	return heightsToTerrain(cfg, heights), nil
+}
+
+// DiamondSquareTerrain is midpoint displacement on a square torus stretched over the map
+type DiamondSquareTerrain struct {
+	// .55 if zero, higher is more rugged
+	Roughness float64
+}
+
+func (g DiamondSquareTerrain) GenerateTerrain(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error) {
+	if g.Roughness == 0 {
+		g.Roughness = .55
+	}
+	n := 1
+	for n < cfg.Width || n < cfg.Height {
+		n *= 2
+	}
+	cfg.logf("diamond-square terrain %vx%v, torus of %v", cfg.Width, cfg.Height, n)
+
+	torus := make([][]float64, n)
+	for y := range torus {
+		torus[y] = make([]float64, n)
+	}
+	at := func(y, x int) float64 {
+		return torus[(y+n)%n][(x+n)%n]
+	}
+	scale := 1.0
+	for step := n; step > 1; step /= 2 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cfg.report(STAGE_TERRAIN, n-step, n)
+		half := step / 2
+		// diamond: centres of squares
+		for y := 0; y < n; y += step {
+			for x := 0; x < n; x += step {
+				avg := (at(y, x) + at(y, x+step) + at(y+step, x) + at(y+step, x+step)) / 4
+				torus[y+half][x+half] = avg + (rng.Float64()*2-1)*scale
+			}
+		}
+		// square: middles of edges
+		for y := 0; y < n; y += half {
+			for x := (y/half + 1) % 2 * half; x < n; x += step {
+				avg := (at(y-half, x) + at(y+half, x) + at(y, x-half) + at(y, x+half)) / 4
+				torus[y][x] = avg + (rng.Float64()*2-1)*scale
+			}
+		}
+		scale *= g.Roughness
+	}
+
+	heights := newHeights(cfg)
+	for y := range heights {
+		for x := range heights[y] {
+			heights[y][x] = torus[y*n/cfg.Height][x*n/cfg.Width]
+		}
+	}
+	cfg.report(STAGE_TERRAIN, 1, 1)
+	return heightsToTerrain(cfg, heights), nil
+}
+
+// PlateTerrain is a Voronoi diagram of continental and oceanic plates,
+// with noisy coasts
+type PlateTerrain struct {
+	// Magic()/2 if zero
+	Plates int
+	// share of continental plates in percent, 40 if zero
+	ContinentalPct int
+}
+
+func (g PlateTerrain) GenerateTerrain(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error) {
+	if g.Plates == 0 {
+		g.Plates = cfg.Magic() / 2
+	}
+	if g.ContinentalPct == 0 {
+		g.ContinentalPct = 40
+	}
+	cfg.logf("plate terrain %vx%v, %v plates", cfg.Width, cfg.Height, g.Plates)
+
+	plateY, plateX := make([]int, g.Plates), make([]int, g.Plates)
+	plateH := make([]float64, g.Plates)
+	for i := range plateY {
+		plateY[i], plateX[i] = rng.Intn(cfg.Height), rng.Intn(cfg.Width)
+		if rng.Intn(100) < g.ContinentalPct {
+			plateH[i] = .5 + rng.Float64()/2
+		} else {
+			plateH[i] = -.5 - rng.Float64()/2
+		}
+	}
+
+	heights, err := fbm(ctx, cfg, rng, 5, .5)
+	if err != nil {
+		return nil, err
+	}
+	// plates blend into each other over this many squares
+	blend := float64(cfg.Magic()) / 4
+	for y := range heights {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for x := range heights[y] {
+			d1, d2 := math.Inf(1), math.Inf(1)
+			p1, p2 := 0, 0
+			for i := range plateY {
+				dy, dx := cfg.Offset(y, x, plateY[i], plateX[i])
+				d := math.Hypot(float64(dy), float64(dx))
+				if d < d1 {
+					d1, d2, p1, p2 = d, d1, i, p1
+				} else if d < d2 {
+					d2, p2 = d, i
+				}
+			}
+			h := plateH[p1]
+			if t := (d2 - d1) / blend; t < 1 {
+				h = plateH[p2] + (.5+t/2)*(plateH[p1]-plateH[p2])
+			}
+			heights[y][x] = h + heights[y][x]/2
+		}
+	}
+	cfg.report(STAGE_TERRAIN, 1, 1)
+	return heightsToTerrain(cfg, heights), nil
+}
@@ -0,0 +1,48 @@
+package lgc
+
+import (
+	"image/color"
+	"math/rand"
+)
+
+// water colder than this freezes into an ice shelf
+const CLIMATE_SEA_ICE_TEMP = -8
+
+var (
+	ICE_SHELF_COLOR = color.RGBA{205, 225, 240, 255}
+	POLE_COLOR      = color.RGBA{240, 245, 250, 255}
+)
+
+// IsFrozen is true for ice shelves, glaciers and the poles
+func (st *SquareTerrain) IsFrozen() bool {
+	return st.Biome == BIOME_ICE
+}
+
+// DrawIce cracks ice shelves and glaciers
+func (st *SquareTerrain) DrawIce(rng *rand.Rand) error {
+	crack := color.RGBA{160, 185, 210, 255}
+	colors := map[byte]color.Color{
+		'#': crack,
+		'.': color.Transparent,
+	}
+	shapes := [...]string{
+		"..........#........#.......##........#..........................",
+		"......................#......#....###....#......................",
+		"................................................................",
+	}
+	return st.Draw(shapes[rng.Intn(len(shapes))], colors)
+}
+
+// DrawPole draws the wind-carved snow of the rows beyond the last latitude
+func (st *SquareTerrain) DrawPole(rng *rand.Rand) error {
+	ridge := color.RGBA{200, 215, 230, 255}
+	colors := map[byte]color.Color{
+		'~': ridge,
+		'.': color.Transparent,
+	}
+	shapes := [...]string{
+		"........~~~........~~.......................~~~.~~....~~........",
+		"............~~..~~~~..~~.................~~~........~~~.........",
+	}
+	return st.Draw(shapes[rng.Intn(len(shapes))], colors)
+}
@@ -0,0 +1,179 @@
+package lgc
+
+import (
+	"container/heap"
+	"context"
+	"image/color"
+)
+
+const (
+	// hamlets only have fishing boats
+	PORT_MIN_TIER = CITY_TIER_TOWN
+	// every port is linked to that many of its nearest neighbours
+	SEA_LANE_NEIGHBOURS = 2
+)
+
+// cost of sailing through a square of each depth class, ships keeping
+// off the shallows but to come in or out of a port
+var SEA_LANE_COSTS = [...]float64{
+	SEA_DEPTH_SHALLOW: 4,
+	SEA_DEPTH_SHELF:   1.5,
+	SEA_DEPTH_DEEP:    1,
+	SEA_DEPTH_TRENCH:  1,
+}
+
+var SEA_LANE_COLOR = color.RGBA{235, 235, 220, 255}
+
+// Port is where the ships of a coastal city anchor
+type Port struct {
+	// index in World.Cities
+	City int
+	// the shallow sea square of the anchorage
+	Y, X int
+}
+
+// SeaLane is the shortest way by sea between two ports
+type SeaLane struct {
+	// indexes in World.Ports
+	From, To int
+	// sea squares from the anchorage of From to the one of To
+	Y, X []int
+}
+
+// IsNavigable is true for the open sea
+func (st *SquareTerrain) IsNavigable() bool {
+	return st.Terrain == TERRAIN_SEA && !st.IsFrozen()
+}
+
+// seaPaths finds the cheapest ways by sea, off the map borders, from a square
+// to every other one with Dijkstra, returning their costs, -1 where unreachable, and the index
+// of the square each one is reached from
+func (grid *Grid) seaPaths(fromY, fromX int) (cost [][]float64, prev [][]int) {
+	cost = make([][]float64, grid.Height)
+	prev = make([][]int, grid.Height)
+	for y := range cost {
+		cost[y] = make([]float64, grid.Width)
+		prev[y] = make([]int, grid.Width)
+		for x := range cost[y] {
+			cost[y][x] = -1
+			prev[y][x] = -1
+		}
+	}
+	cost[fromY][fromX] = 0
+	queue := &squareQueue{{fromY, fromX, 0}}
+	for queue.Len() > 0 {
+		s := heap.Pop(queue).(squareItem)
+		if s.priority > cost[s.y][s.x] {
+			continue
+		}
+		for _, dir := range DIR_NEXT {
+			ny, nx, ok := grid.Neighbour(s.y, s.x, dir)
+			if !ok || grid.OnBorder(ny, nx) || !grid.Squares[ny][nx].IsNavigable() {
+				continue
+			}
+			if c := s.priority + SEA_LANE_COSTS[grid.Squares[ny][nx].SeaDepth]; cost[ny][nx] == -1 || c < cost[ny][nx] {
+				cost[ny][nx] = c
+				prev[ny][nx] = s.y*grid.Width + s.x
+				heap.Push(queue, squareItem{ny, nx, c})
+			}
+		}
+	}
+	return cost, prev
+}
+
+// AddSeaLanes opens a port in every town or bigger city on a shallow coast,
+// then links each port to its nearest neighbours by sea, and each group of
+// ports on a sea together
+func (world *World) AddSeaLanes(ctx context.Context) error {
+	grid := world.Grid
+	world.Ports = nil
+	for ic, city := range world.Cities {
+		if city.Tier < PORT_MIN_TIER {
+			continue
+		}
+	squares:
+		for i := range city.Y {
+			for _, dir := range DIR_NEXT {
+				ny, nx, ok := grid.Neighbour(city.Y[i], city.X[i], dir)
+				if st := grid.Squares[ny][nx]; ok && !grid.OnBorder(ny, nx) && st.IsShallow() && st.IsNavigable() {
+					world.Ports = append(world.Ports, &Port{City: ic, Y: ny, X: nx})
+					break squares
+				}
+			}
+		}
+	}
+
+	var links []nodeLink
+	prevs := make([][][]int, len(world.Ports))
+	for a, pa := range world.Ports {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		world.report(STAGE_SEA_LANES, a, 2*len(world.Ports))
+		var cost [][]float64
+		cost, prevs[a] = grid.seaPaths(pa.Y, pa.X)
+		for b := a + 1; b < len(world.Ports); b++ {
+			if c := cost[world.Ports[b].Y][world.Ports[b].X]; c > 0 {
+				links = append(links, nodeLink{a, b, c})
+			}
+		}
+	}
+	world.SeaLanes = nil
+	err := linkNodes(links, len(world.Ports), SEA_LANE_NEIGHBOURS, func(i int, l nodeLink) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		world.report(STAGE_SEA_LANES, len(world.Ports)+i*len(world.Ports)/len(links), 2*len(world.Ports))
+		lane := &SeaLane{From: l.a, To: l.b}
+		pb := world.Ports[l.b]
+		for y, x := pb.Y, pb.X; ; {
+			lane.Y, lane.X = append(lane.Y, y), append(lane.X, x)
+			p := prevs[l.a][y][x]
+			if p == -1 {
+				break
+			}
+			y, x = p/grid.Width, p%grid.Width
+		}
+		// walked back from b
+		for i, j := 0, len(lane.Y)-1; i < j; i, j = i+1, j-1 {
+			lane.Y[i], lane.Y[j] = lane.Y[j], lane.Y[i]
+			lane.X[i], lane.X[j] = lane.X[j], lane.X[i]
+		}
+		world.SeaLanes = append(world.SeaLanes, lane)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	world.logf("sea lanes: %v ports, %v lanes", len(world.Ports), len(world.SeaLanes))
+	return nil
+}
+
+// DrawSeaLane dashes the square across the middle, along dir
+func (st *SquareTerrain) DrawSeaLane(dir [2]int) {
+	h, w := len(st.Colors), len(st.Colors[0])
+	if dir[0] == 0 {
+		for sx := w / 4; sx < w-w/4; sx++ {
+			st.Colors[h/2][sx] = SEA_LANE_COLOR
+		}
+	} else {
+		for sy := h / 4; sy < h-h/4; sy++ {
+			st.Colors[sy][w/2] = SEA_LANE_COLOR
+		}
+	}
+}
+
+// drawSeaLanes dashes every other square of the lanes
+func (world *World) drawSeaLanes() {
+	grid := world.Grid
+	for _, lane := range world.SeaLanes {
+		for i := 1; i < len(lane.Y)-1; i += 2 {
+			dy, _ := grid.Offset(lane.Y[i-1], lane.X[i-1], lane.Y[i+1], lane.X[i+1])
+			dir := [2]int{0, 1}
+			if Abs(dy) == 2 {
+				dir = [2]int{1, 0}
+			}
+			grid.Squares[lane.Y[i]][lane.X[i]].DrawSeaLane(dir)
+		}
+	}
+}
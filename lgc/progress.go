@@ -0,0 +1,59 @@
+package lgc
+
+// stages of a generation, in order
+const (
+	STAGE_TERRAIN = iota
+	STAGE_ISOLATED
+	STAGE_SEA_LEVEL
+	STAGE_LAND_AND_SEA
+	STAGE_SMOOTH
+	STAGE_EROSION
+	STAGE_MOUNTAINS
+	STAGE_LAKES
+	STAGE_SEA_DEPTHS
+	STAGE_LANDMASSES
+	STAGE_CLIMATE
+	STAGE_RIVERS
+	STAGE_CITIES
+	STAGE_COUNTRIES
+	STAGE_ROADS
+	STAGE_SEA_LANES
+	STAGE_MAP_BORDERS
+	STAGE_COLORS
+	STAGE_DECORATION
+)
+
+var STAGE_NAMES = [...]string{
+	STAGE_TERRAIN:      "terrain",
+	STAGE_ISOLATED:     "isolation cleanup",
+	STAGE_SEA_LEVEL:    "sea level",
+	STAGE_LAND_AND_SEA: "land and sea",
+	STAGE_SMOOTH:       "smoothing",
+	STAGE_EROSION:      "erosion",
+	STAGE_MOUNTAINS:    "mountains",
+	STAGE_LAKES:        "lakes",
+	STAGE_SEA_DEPTHS:   "sea depths",
+	STAGE_LANDMASSES:   "landmasses",
+	STAGE_CLIMATE:      "climate",
+	STAGE_RIVERS:       "rivers",
+	STAGE_CITIES:       "cities",
+	STAGE_COUNTRIES:    "countries",
+	STAGE_ROADS:        "roads",
+	STAGE_SEA_LANES:    "sea lanes",
+	STAGE_MAP_BORDERS:  "map borders",
+	STAGE_COLORS:       "colors",
+	STAGE_DECORATION:   "decoration",
+}
+
+// ProgressFunc is told how far into a stage the generation is, in percent
+type ProgressFunc func(stage, percent int)
+
+func (cfg *Config) report(stage, done, total int) {
+	if cfg.Progress == nil || total <= 0 {
+		return
+	}
+	if done > total {
+		done = total
+	}
+	cfg.Progress(stage, done*100/total)
+}
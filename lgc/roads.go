@@ -0,0 +1,337 @@
+package lgc
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"sort"
+)
+
+// road building costs of a square, on top of the base cost of 1
+const (
+	ROAD_COST_MOUNTAIN float64 = 5
+	ROAD_COST_ICE      float64 = 3
+	// for a climb or descent of the whole 0..255 land scale
+	ROAD_COST_SLOPE  float64 = 30
+	ROAD_COST_BRIDGE float64 = 6
+	ROAD_COST_BORDER float64 = 8
+	// share of the cost left where a road already goes, so roads merge
+	ROAD_REUSE float64 = .3
+	// every city is linked to that many of its nearest neighbours
+	ROAD_NEIGHBOURS = 2
+)
+
+// Road is a stretch of the network between two of its nodes
+type Road struct {
+	// indexes in World.RoadNodes
+	From, To int
+	// road squares in between, from From to To
+	Y, X []int
+}
+
+// RoadNode is a city or a junction of the road network
+type RoadNode struct {
+	Y, X int
+	// index in World.Cities, -1 at a junction
+	City int
+}
+
+// IsBridge is true where a road crosses a river
+func (st *SquareTerrain) IsBridge() bool {
+	return st.Road && st.Feature == FEATURE_RIVER
+}
+
+// nodeLink is a way that could be built between nodes a and b of a network
+type nodeLink struct {
+	a, b int
+	cost float64
+}
+
+// linkNodes goes through the links between n nodes, cheapest first, and
+// builds those that reach one of the k nearest neighbours of a or b, or that
+// join two groups of nodes not linked yet; build tells whether it could
+func linkNodes(links []nodeLink, n, k int, build func(i int, l nodeLink) (bool, error)) error {
+	sort.SliceStable(links, func(i, j int) bool { return links[i].cost < links[j].cost })
+	nearest := make([]int, n)
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i, l := range links {
+		near := nearest[l.a] < k || nearest[l.b] < k
+		nearest[l.a]++
+		nearest[l.b]++
+		if !near && find(l.a) == find(l.b) {
+			continue
+		}
+		built, err := build(i, l)
+		if err != nil {
+			return err
+		}
+		if built {
+			parent[find(l.a)] = find(l.b)
+		}
+	}
+	return nil
+}
+
+// roadCost is the cost of building a road from a square to the next one,
+// negative where no road can go, as on the map borders
+func (grid *Grid) roadCost(el [][]float64, y, x, ny, nx int) float64 {
+	st, next := grid.Squares[y][x], grid.Squares[ny][nx]
+	if grid.OnBorder(ny, nx) || (next.Terrain != TERRAIN_LAND && next.Terrain != TERRAIN_MOUNTAIN) {
+		return -1
+	}
+	cost := 1.0
+	switch {
+	case next.Terrain == TERRAIN_MOUNTAIN:
+		cost += ROAD_COST_MOUNTAIN
+	case next.IsFrozen():
+		cost += ROAD_COST_ICE
+	}
+	cost += math.Abs(el[ny][nx]-el[y][x]) / 255 * ROAD_COST_SLOPE
+	if next.Feature == FEATURE_RIVER {
+		cost += ROAD_COST_BRIDGE
+	}
+	if st.CountryIndex != next.CountryIndex && st.CountryIndex != -1 && next.CountryIndex != -1 {
+		cost += ROAD_COST_BORDER
+	}
+	if next.Road || next.CityIndex != -1 {
+		cost *= ROAD_REUSE
+	}
+	return cost
+}
+
+// roadPath finds the cheapest way from a square to another with A*, nil if
+// there is none
+func (grid *Grid) roadPath(el [][]float64, fromY, fromX, toY, toX int) (ys, xs []int) {
+	cost := make([][]float64, grid.Height)
+	prev := make([][]int, grid.Height)
+	for y := range cost {
+		cost[y] = make([]float64, grid.Width)
+		prev[y] = make([]int, grid.Width)
+		for x := range cost[y] {
+			cost[y][x] = -1
+			prev[y][x] = -1
+		}
+	}
+	// no square costs less than a reused road, which keeps the estimate low
+	estimate := func(y, x int) float64 {
+		dy, dx := grid.Offset(y, x, toY, toX)
+		return float64(Abs(dy)+Abs(dx)) * ROAD_REUSE
+	}
+	cost[fromY][fromX] = 0
+	queue := &squareQueue{{fromY, fromX, estimate(fromY, fromX)}}
+	for queue.Len() > 0 {
+		s := heap.Pop(queue).(squareItem)
+		if s.y == toY && s.x == toX {
+			break
+		}
+		if s.priority > cost[s.y][s.x]+estimate(s.y, s.x) {
+			// already reached cheaper
+			continue
+		}
+		for _, dir := range DIR_NEXT {
+			ny, nx, ok := grid.Neighbour(s.y, s.x, dir)
+			if !ok {
+				continue
+			}
+			step := grid.roadCost(el, s.y, s.x, ny, nx)
+			if step < 0 {
+				continue
+			}
+			if c := cost[s.y][s.x] + step; cost[ny][nx] == -1 || c < cost[ny][nx] {
+				cost[ny][nx] = c
+				prev[ny][nx] = s.y*grid.Width + s.x
+				heap.Push(queue, squareItem{ny, nx, c + estimate(ny, nx)})
+			}
+		}
+	}
+	if cost[toY][toX] == -1 {
+		return nil, nil
+	}
+	for y, x := toY, toX; ; {
+		ys, xs = append(ys, y), append(xs, x)
+		if y == fromY && x == fromX {
+			break
+		}
+		y, x = prev[y][x]/grid.Width, prev[y][x]%grid.Width
+	}
+	return ys, xs
+}
+
+// AddRoads links every city to its nearest neighbours and each group of
+// cities on a landmass together by the cheapest roads, then splits the
+// network into roads between cities and junctions
+func (world *World) AddRoads(ctx context.Context) error {
+	grid := world.Grid
+	el := elevations(grid)
+	for y := range grid.Squares {
+		for _, st := range grid.Squares[y] {
+			st.Road = false
+		}
+	}
+
+	var links []nodeLink
+	for a, ca := range world.Cities {
+		for b := a + 1; b < len(world.Cities); b++ {
+			cb := world.Cities[b]
+			if grid.Squares[ca.CenterY][ca.CenterX].LandmassIndex != grid.Squares[cb.CenterY][cb.CenterX].LandmassIndex {
+				continue
+			}
+			dy, dx := grid.Offset(ca.CenterY, ca.CenterX, cb.CenterY, cb.CenterX)
+			links = append(links, nodeLink{a, b, float64(dy*dy + dx*dx)})
+		}
+	}
+	built := 0
+	err := linkNodes(links, len(world.Cities), ROAD_NEIGHBOURS, func(i int, l nodeLink) (bool, error) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		world.report(STAGE_ROADS, i, len(links))
+		ca, cb := world.Cities[l.a], world.Cities[l.b]
+		ys, xs := grid.roadPath(el, ca.CenterY, ca.CenterX, cb.CenterY, cb.CenterX)
+		if ys == nil {
+			return false, nil
+		}
+		for j := range ys {
+			if st := grid.Squares[ys[j]][xs[j]]; st.CityIndex == -1 {
+				st.Road = true
+			}
+		}
+		built++
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	world.traceRoads()
+
+	bridges := 0
+	for _, r := range world.Roads {
+		for i := range r.Y {
+			if grid.Squares[r.Y[i]][r.X[i]].IsBridge() {
+				bridges++
+			}
+		}
+	}
+	world.logf("roads: %v links, %v roads, %v junctions, %v bridges", built, len(world.Roads), len(world.RoadNodes)-len(world.Cities), bridges)
+	return nil
+}
+
+// traceRoads turns the road squares into a graph: every city is a node, and
+// so is every road square where the network does not just go on
+func (world *World) traceRoads() {
+	grid := world.Grid
+	// the road squares and cities next to a square, a city counting once
+	type step struct {
+		y, x int
+		city int
+	}
+	next := func(y, x int) []step {
+		var steps []step
+		for _, dir := range DIR_NEXT {
+			ny, nx, ok := grid.Neighbour(y, x, dir)
+			if !ok {
+				continue
+			}
+			st := grid.Squares[ny][nx]
+			switch {
+			case st.Road:
+				steps = append(steps, step{ny, nx, -1})
+			case st.CityIndex != -1:
+				seen := false
+				for _, s := range steps {
+					seen = seen || s.city == st.CityIndex
+				}
+				if !seen && st.CityIndex != grid.Squares[y][x].CityIndex {
+					steps = append(steps, step{ny, nx, st.CityIndex})
+				}
+			}
+		}
+		return steps
+	}
+
+	world.RoadNodes = make([]*RoadNode, len(world.Cities))
+	for i, city := range world.Cities {
+		world.RoadNodes[i] = &RoadNode{Y: city.CenterY, X: city.CenterX, City: i}
+	}
+	junctions := make(map[int]int)
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if st.Road && len(next(y, x)) != 2 {
+				junctions[y*grid.Width+x] = len(world.RoadNodes)
+				world.RoadNodes = append(world.RoadNodes, &RoadNode{Y: y, X: x, City: -1})
+			}
+		}
+	}
+	node := func(s step) (int, bool) {
+		if s.city != -1 {
+			return s.city, true
+		}
+		n, ok := junctions[s.y*grid.Width+s.x]
+		return n, ok
+	}
+
+	// walks from every node along each of its ways out, a road being found
+	// from both of its ends and kept from the lowest node
+	world.Roads = nil
+	done := make(map[[2][2]int]bool)
+	for from, rn := range world.RoadNodes {
+		var starts []step
+		if rn.City == -1 {
+			starts = next(rn.Y, rn.X)
+		} else {
+			city := world.Cities[rn.City]
+			for i := range city.Y {
+				for _, s := range next(city.Y[i], city.X[i]) {
+					if s.city == -1 {
+						starts = append(starts, s)
+					}
+				}
+			}
+		}
+		for _, s := range starts {
+			road := &Road{From: from}
+			prev := step{rn.Y, rn.X, rn.City}
+			for {
+				if to, ok := node(s); ok {
+					road.To = to
+					break
+				}
+				road.Y, road.X = append(road.Y, s.y), append(road.X, s.x)
+				steps := next(s.y, s.x)
+				nextStep := steps[0]
+				if steps[0] == prev || (prev.city != -1 && steps[0].city == prev.city) {
+					nextStep = steps[1]
+				}
+				prev, s = s, nextStep
+			}
+			if road.To == road.From && len(road.Y) == 0 {
+				continue
+			}
+			// the nodes and the road squares next to them tell roads apart
+			first, last := [2]int{road.From, -1}, [2]int{road.To, -1}
+			if len(road.Y) > 0 {
+				first[1] = road.Y[0]*grid.Width + road.X[0]
+				last[1] = road.Y[len(road.Y)-1]*grid.Width + road.X[len(road.X)-1]
+			}
+			key := [2][2]int{first, last}
+			if first[0] > last[0] || (first[0] == last[0] && first[1] > last[1]) {
+				key = [2][2]int{last, first}
+			}
+			if done[key] {
+				continue
+			}
+			done[key] = true
+			world.Roads = append(world.Roads, road)
+		}
+	}
+}
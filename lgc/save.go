@@ -0,0 +1,472 @@
+package lgc
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"reflect"
+)
+
+// bump when the saved layout changes
+const SAVE_VERSION = 1
+
+// savedWorld is what goes on disk, pointers are replaced by indexes
+type savedWorld struct {
+	Version       int
+	Config        savedConfig
+	Squares       [][]savedSquare
+	Rivers        []savedRiver
+	Cities        []*City
+	Countries     []savedCountry
+	NbLand, NbSea int
+	NbLake        int
+	Landmasses    []*Landmass
+	Archipelagos  []*Archipelago
+	Roads         []*Road
+	RoadNodes     []*RoadNode
+	Ports         []*Port
+	SeaLanes      []*SeaLane
+	MountainMask  [][]bool
+}
+
+type savedConfig struct {
+	Width, Height             int
+	ConnectY, ConnectX        bool
+	Seed                      int64
+	RiverPct                  int
+	RiverMode                 int
+	NbCities, NbCountries     int
+	Frames, SpawnPower        int
+	SquareWidth, SquareHeight int
+	LandPct                   int
+	Erosion, ErosionPct       int
+	CityGrowth                int
+	CountryCosts              CountryCosts
+	// name in TERRAIN_GENERATORS, "" for a generator that cannot be saved,
+	// and its settings as JSON
+	Terrain         string
+	TerrainSettings json.RawMessage
+}
+
+type savedSquare struct {
+	Val           int
+	Terrain       int
+	Feature       int
+	CountryIndex  int
+	Flow, FlowTo  int
+	Temperature   int
+	Precipitation int
+	Biome         int
+	SeaDepth      int
+	LandmassIndex int
+	CityIndex     int
+	Road          bool
+	// RGBA, row by row
+	Pixels []byte
+}
+
+type savedRiver struct {
+	Y, X      []int
+	PathStack []int
+	Level     int
+}
+
+type savedCountry struct {
+	// indexes in savedWorld.Cities
+	Cities           []int
+	Y, X             []int
+	BorderY, BorderX []int
+	Color            color.RGBA
+}
+
+func toRGBA(c color.Color) color.RGBA {
+	if c == nil {
+		return color.RGBA{}
+	}
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+func (world *World) saved() *savedWorld {
+	cfg := world.Config
+	sw := &savedWorld{
+		Version: SAVE_VERSION,
+		Config: savedConfig{
+			Width:        cfg.Width,
+			Height:       cfg.Height,
+			ConnectY:     cfg.ConnectY,
+			ConnectX:     cfg.ConnectX,
+			Seed:         cfg.Seed,
+			RiverPct:     cfg.RiverPct,
+			RiverMode:    cfg.RiverMode,
+			NbCities:     cfg.NbCities,
+			NbCountries:  cfg.NbCountries,
+			Frames:       cfg.Frames,
+			SpawnPower:   cfg.SpawnPower,
+			SquareWidth:  cfg.SquareWidth,
+			SquareHeight: cfg.SquareHeight,
+			LandPct:      cfg.LandPct,
+			Erosion:      cfg.Erosion,
+			ErosionPct:   cfg.ErosionPct,
+			CityGrowth:   cfg.CityGrowth,
+			CountryCosts: cfg.CountryCosts,
+			Terrain:      TerrainGeneratorName(cfg.Terrain),
+		},
+		Squares:      make([][]savedSquare, len(world.Grid.Squares)),
+		Cities:       world.Cities,
+		NbLand:       world.NbLand,
+		NbSea:        world.NbSea,
+		NbLake:       world.NbLake,
+		Landmasses:   world.Landmasses,
+		Archipelagos: world.Archipelagos,
+		Roads:        world.Roads,
+		RoadNodes:    world.RoadNodes,
+		Ports:        world.Ports,
+		SeaLanes:     world.SeaLanes,
+		MountainMask: world.MountainMask,
+	}
+	if _, ok := cfg.Terrain.(TerrainFunc); !ok && sw.Config.Terrain != "" {
+		// plain structs, which cannot fail
+		sw.Config.TerrainSettings, _ = json.Marshal(cfg.Terrain)
+	}
+	for y, row := range world.Grid.Squares {
+		sw.Squares[y] = make([]savedSquare, len(row))
+		for x, st := range row {
+			pixels := make([]byte, 0, 4*cfg.SquareWidth*cfg.SquareHeight)
+			for sy := range st.Colors {
+				for sx := range st.Colors[sy] {
+					c := toRGBA(st.Colors[sy][sx])
+					pixels = append(pixels, c.R, c.G, c.B, c.A)
+				}
+			}
+			sw.Squares[y][x] = savedSquare{
+				Val:           st.Val,
+				Terrain:       st.Terrain,
+				Feature:       st.Feature,
+				CountryIndex:  st.CountryIndex,
+				Flow:          st.Flow,
+				FlowTo:        st.FlowTo,
+				Temperature:   st.Temperature,
+				Precipitation: st.Precipitation,
+				Biome:         st.Biome,
+				SeaDepth:      st.SeaDepth,
+				LandmassIndex: st.LandmassIndex,
+				CityIndex:     st.CityIndex,
+				Road:          st.Road,
+				Pixels:        pixels,
+			}
+		}
+	}
+	for _, r := range world.Rivers {
+		sw.Rivers = append(sw.Rivers, savedRiver{
+			Y:         r.y,
+			X:         r.x,
+			PathStack: r.pathStack,
+			Level:     r.Level,
+		})
+	}
+	cityIndex := make(map[*City]int, len(world.Cities))
+	for i, city := range world.Cities {
+		cityIndex[city] = i
+	}
+	if world.Countries != nil {
+		for _, c := range world.Countries.countries {
+			country := savedCountry{
+				Y:       c.Y,
+				X:       c.X,
+				BorderY: c.BorderY,
+				BorderX: c.BorderX,
+				Color:   toRGBA(c.Color),
+			}
+			for _, city := range c.Cities {
+				country.Cities = append(country.Cities, cityIndex[city])
+			}
+			sw.Countries = append(sw.Countries, country)
+		}
+	}
+	return sw
+}
+
+func (sw *savedWorld) world() (*World, error) {
+	if sw.Version != SAVE_VERSION {
+		return nil, fmt.Errorf("unsupported save version %v, expected %v", sw.Version, SAVE_VERSION)
+	}
+	cfg := NewConfig(sw.Config.Width, sw.Config.Height)
+	cfg.ConnectY, cfg.ConnectX = sw.Config.ConnectY, sw.Config.ConnectX
+	cfg.Seed = sw.Config.Seed
+	cfg.RiverPct, cfg.RiverMode = sw.Config.RiverPct, sw.Config.RiverMode
+	cfg.NbCities, cfg.NbCountries = sw.Config.NbCities, sw.Config.NbCountries
+	cfg.Frames, cfg.SpawnPower = sw.Config.Frames, sw.Config.SpawnPower
+	cfg.SquareWidth, cfg.SquareHeight = sw.Config.SquareWidth, sw.Config.SquareHeight
+	cfg.LandPct = sw.Config.LandPct
+	cfg.Erosion, cfg.ErosionPct = sw.Config.Erosion, sw.Config.ErosionPct
+	cfg.CityGrowth, cfg.CountryCosts = sw.Config.CityGrowth, sw.Config.CountryCosts
+	if sw.Config.Terrain != "" {
+		gen, err := NewTerrainGenerator(sw.Config.Terrain)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := gen.(TerrainFunc); !ok && len(sw.Config.TerrainSettings) > 0 {
+			settings := reflect.New(reflect.TypeOf(gen))
+			if err := json.Unmarshal(sw.Config.TerrainSettings, settings.Interface()); err != nil {
+				return nil, fmt.Errorf("saved %v terrain settings: %v", sw.Config.Terrain, err)
+			}
+			gen = settings.Elem().Interface().(TerrainGenerator)
+		}
+		cfg.Terrain = gen
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if len(sw.Squares) != cfg.Height {
+		return nil, fmt.Errorf("saved grid has %v rows, expected %v", len(sw.Squares), cfg.Height)
+	}
+
+	world := &World{
+		Config: cfg,
+		Grid:   NewGrid(cfg),
+		Cities: sw.Cities,
+		NbLand: sw.NbLand,
+		NbSea:  sw.NbSea,
+		NbLake: sw.NbLake,
+		rng:    cfg.NewRand(),
+
+		Landmasses:   sw.Landmasses,
+		Archipelagos: sw.Archipelagos,
+		Roads:        sw.Roads,
+		RoadNodes:    sw.RoadNodes,
+		Ports:        sw.Ports,
+		SeaLanes:     sw.SeaLanes,
+		MountainMask: sw.MountainMask,
+	}
+	if world.MountainMask != nil {
+		if len(world.MountainMask) != cfg.Height {
+			return nil, fmt.Errorf("saved mountain mask has %v rows, expected %v", len(world.MountainMask), cfg.Height)
+		}
+		for y, row := range world.MountainMask {
+			if len(row) != cfg.Width {
+				return nil, fmt.Errorf("saved mountain mask row %v has %v squares, expected %v", y, len(row), cfg.Width)
+			}
+		}
+	}
+	for i, city := range world.Cities {
+		if city.Tier < 0 || city.Tier >= len(CITY_TIER_NAMES) {
+			return nil, fmt.Errorf("saved city %v has unknown tier %v", i, city.Tier)
+		}
+		if err := cfg.checkSquares(fmt.Sprint("city ", i), append([]int{city.CenterY}, city.Y...), append([]int{city.CenterX}, city.X...)); err != nil {
+			return nil, err
+		}
+	}
+	for i, rn := range world.RoadNodes {
+		if rn.City < -1 || rn.City >= len(world.Cities) {
+			return nil, fmt.Errorf("saved road node %v has unknown city %v", i, rn.City)
+		}
+		if err := cfg.checkSquares(fmt.Sprint("road node ", i), []int{rn.Y}, []int{rn.X}); err != nil {
+			return nil, err
+		}
+	}
+	for i, r := range world.Roads {
+		if r.From < 0 || r.From >= len(world.RoadNodes) || r.To < 0 || r.To >= len(world.RoadNodes) {
+			return nil, fmt.Errorf("saved road %v has unknown nodes %v and %v", i, r.From, r.To)
+		}
+		if err := cfg.checkSquares(fmt.Sprint("road ", i), r.Y, r.X); err != nil {
+			return nil, err
+		}
+	}
+	for i, p := range world.Ports {
+		if p.City < 0 || p.City >= len(world.Cities) {
+			return nil, fmt.Errorf("saved port %v has unknown city %v", i, p.City)
+		}
+		if err := cfg.checkSquares(fmt.Sprint("port ", i), []int{p.Y}, []int{p.X}); err != nil {
+			return nil, err
+		}
+	}
+	for i, l := range world.SeaLanes {
+		if l.From < 0 || l.From >= len(world.Ports) || l.To < 0 || l.To >= len(world.Ports) {
+			return nil, fmt.Errorf("saved sea lane %v has unknown ports %v and %v", i, l.From, l.To)
+		}
+		if err := cfg.checkSquares(fmt.Sprint("sea lane ", i), l.Y, l.X); err != nil {
+			return nil, err
+		}
+	}
+	for i, lm := range world.Landmasses {
+		if lm.Kind < 0 || lm.Kind >= len(LANDMASS_KIND_NAMES) {
+			return nil, fmt.Errorf("saved landmass %v has unknown kind %v", i, lm.Kind)
+		}
+		if lm.Archipelago < -1 || lm.Archipelago >= len(world.Archipelagos) {
+			return nil, fmt.Errorf("saved landmass %v has unknown archipelago %v", i, lm.Archipelago)
+		}
+		if err := cfg.checkSquares(fmt.Sprint("landmass ", i), []int{lm.Y}, []int{lm.X}); err != nil {
+			return nil, err
+		}
+	}
+	for i, a := range world.Archipelagos {
+		if len(a.Landmasses) == 0 {
+			return nil, fmt.Errorf("saved archipelago %v has no landmass", i)
+		}
+		for _, il := range a.Landmasses {
+			if il < 0 || il >= len(world.Landmasses) {
+				return nil, fmt.Errorf("saved archipelago %v has unknown landmass %v", i, il)
+			}
+		}
+	}
+	for y, row := range sw.Squares {
+		if len(row) != cfg.Width {
+			return nil, fmt.Errorf("saved grid row %v has %v squares, expected %v", y, len(row), cfg.Width)
+		}
+		for x, ss := range row {
+			if len(ss.Pixels) != 4*cfg.SquareWidth*cfg.SquareHeight {
+				return nil, fmt.Errorf("saved square %v,%v has %v bytes of pixels, expected %v", y, x, len(ss.Pixels), 4*cfg.SquareWidth*cfg.SquareHeight)
+			}
+			if err := ss.check(len(sw.Countries)); err != nil {
+				return nil, fmt.Errorf("saved square %v,%v %v", y, x, err)
+			}
+			st := NewSquareTerrain(cfg, ss.Val)
+			st.Terrain = ss.Terrain
+			st.Feature = ss.Feature
+			st.CountryIndex = ss.CountryIndex
+			st.Flow, st.FlowTo = ss.Flow, ss.FlowTo
+			st.Temperature, st.Precipitation, st.Biome = ss.Temperature, ss.Precipitation, ss.Biome
+			st.SeaDepth, st.LandmassIndex = ss.SeaDepth, ss.LandmassIndex
+			if st.LandmassIndex < -1 || st.LandmassIndex >= len(world.Landmasses) {
+				return nil, fmt.Errorf("saved square %v,%v has unknown landmass %v", y, x, st.LandmassIndex)
+			}
+			st.CityIndex, st.Road = ss.CityIndex, ss.Road
+			if st.CityIndex < -1 || st.CityIndex >= len(world.Cities) {
+				return nil, fmt.Errorf("saved square %v,%v has unknown city %v", y, x, st.CityIndex)
+			}
+			for sy := range st.Colors {
+				for sx := range st.Colors[sy] {
+					p := ss.Pixels[4*(sy*cfg.SquareWidth+sx):]
+					st.Colors[sy][sx] = color.RGBA{p[0], p[1], p[2], p[3]}
+				}
+			}
+			world.Grid.Squares[y][x] = st
+		}
+	}
+	for i, sr := range sw.Rivers {
+		if err := cfg.checkSquares(fmt.Sprint("river ", i), sr.Y, sr.X); err != nil {
+			return nil, err
+		}
+		for _, j := range sr.PathStack {
+			if j < 0 || j >= len(sr.Y) {
+				return nil, fmt.Errorf("saved river %v goes back to unknown square %v", i, j)
+			}
+		}
+		world.Rivers = append(world.Rivers, &River{
+			y:         sr.Y,
+			x:         sr.X,
+			pathStack: sr.PathStack,
+			Level:     sr.Level,
+		})
+	}
+	world.Countries = NewCountryGroup(world.Grid)
+	for i, sc := range sw.Countries {
+		if err := cfg.checkSquares(fmt.Sprint("country ", i), sc.Y, sc.X); err != nil {
+			return nil, err
+		}
+		if err := cfg.checkSquares(fmt.Sprint("country ", i, " border"), sc.BorderY, sc.BorderX); err != nil {
+			return nil, err
+		}
+		country := &Country{
+			Y:       sc.Y,
+			X:       sc.X,
+			BorderY: sc.BorderY,
+			BorderX: sc.BorderX,
+			Color:   sc.Color,
+			CG:      world.Countries,
+		}
+		for _, ic := range sc.Cities {
+			if ic < 0 || ic >= len(world.Cities) {
+				return nil, fmt.Errorf("saved country %v has unknown city %v", i, ic)
+			}
+			country.Cities = append(country.Cities, world.Cities[ic])
+		}
+		world.Countries.AddCountry(country)
+	}
+	return world, nil
+}
+
+// checkSquares is an error unless ys and xs pair up into squares of the map
+func (cfg *Config) checkSquares(what string, ys, xs []int) error {
+	if len(ys) != len(xs) {
+		return fmt.Errorf("saved %v has %v rows for %v columns", what, len(ys), len(xs))
+	}
+	for i := range ys {
+		if ys[i] < 0 || ys[i] >= cfg.Height || xs[i] < 0 || xs[i] >= cfg.Width {
+			return fmt.Errorf("saved %v has square %v,%v off the %vx%v map", what, ys[i], xs[i], cfg.Width, cfg.Height)
+		}
+	}
+	return nil
+}
+
+// check is an error if a saved square has an unknown terrain, feature,
+// class or direction, or belongs to one of more than nbCountries countries
+func (ss *savedSquare) check(nbCountries int) error {
+	switch {
+	case ss.Terrain < TERRAIN_SEA || ss.Terrain > TERRAIN_LAKE:
+		return fmt.Errorf("has unknown terrain %v", ss.Terrain)
+	case ss.Feature < FEATURE_NONE || ss.Feature > FEATURE_COUNTRY_BORDER:
+		return fmt.Errorf("has unknown feature %v", ss.Feature)
+	case ss.Biome < 0 || ss.Biome >= len(BIOME_NAMES):
+		return fmt.Errorf("has unknown biome %v", ss.Biome)
+	case ss.SeaDepth < 0 || ss.SeaDepth >= len(SEA_DEPTH_NAMES):
+		return fmt.Errorf("has unknown sea depth %v", ss.SeaDepth)
+	case ss.FlowTo < 0 || ss.FlowTo >= len(DIR_NEXT):
+		return fmt.Errorf("flows to unknown direction %v", ss.FlowTo)
+	case ss.CountryIndex < -1 || ss.CountryIndex >= nbCountries:
+		return fmt.Errorf("has unknown country %v", ss.CountryIndex)
+	}
+	return nil
+}
+
+// SaveJSON writes the whole world as indented JSON
+func SaveJSON(world *World, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(world.saved())
+}
+
+// LoadJSON reads a world written by SaveJSON
+func LoadJSON(r io.Reader) (*World, error) {
+	var sw savedWorld
+	if err := json.NewDecoder(r).Decode(&sw); err != nil {
+		return nil, err
+	}
+	return sw.world()
+}
+
+// SaveGob writes the whole world in the compact gob encoding
+func SaveGob(world *World, w io.Writer) error {
+	return gob.NewEncoder(w).Encode(world.saved())
+}
+
+// Load reads a world written by SaveJSON or SaveGob
+func Load(r io.Reader) (*World, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+			continue
+		case '{':
+			return LoadJSON(br)
+		}
+		return LoadGob(br)
+	}
+}
+
+// LoadGob reads a world written by SaveGob
+func LoadGob(r io.Reader) (*World, error) {
+	var sw savedWorld
+	if err := gob.NewDecoder(r).Decode(&sw); err != nil {
+		return nil, err
+	}
+	return sw.world()
+}
@@ -0,0 +1,126 @@
+package lgc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestSaveRoundTrip(t *testing.T) {
+	formats := []struct {
+		name string
+		save func(*World, io.Writer) error
+	}{
+		{"json", SaveJSON},
+		{"gob", SaveGob},
+	}
+	for _, tc := range []struct {
+		terrain string
+		setup   func(cfg *Config)
+	}{
+		{"quick", nil},
+		{"fbm", func(cfg *Config) {
+			cfg.ConnectX, cfg.LandPct = true, 45
+			cfg.Terrain = FBMTerrain{Octaves: 4, Persistence: .6}
+		}},
+		{"plates", func(cfg *Config) {
+			cfg.ConnectY, cfg.ConnectX = true, true
+			cfg.RiverMode, cfg.CityGrowth = RIVER_MODE_FLOW, 4
+		}},
+		{"tectonics", func(cfg *Config) {
+			cfg.Erosion, cfg.ErosionPct = 300, 70
+			cfg.CountryCosts = CountryCosts{Distance: 2, Mountain: 1, River: 3}
+		}},
+	} {
+		cfg := testConfig(11, tc.terrain)
+		if tc.setup != nil {
+			tc.setup(cfg)
+		}
+		world := testWorld(t, cfg)
+		for _, f := range formats {
+			t.Run(tc.terrain+"/"+f.name, func(t *testing.T) {
+				var saved bytes.Buffer
+				if err := f.save(world, &saved); err != nil {
+					t.Fatal(err)
+				}
+				loaded, err := Load(bytes.NewReader(saved.Bytes()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				var again bytes.Buffer
+				if err := f.save(loaded, &again); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(saved.Bytes(), again.Bytes()) {
+					t.Error("the loaded world is not saved the same")
+				}
+
+				// what a new generation would need
+				want, got := *cfg, *loaded.Config
+				if TerrainGeneratorName(got.Terrain) != TerrainGeneratorName(want.Terrain) {
+					t.Errorf("terrain %v, expected %v", TerrainGeneratorName(got.Terrain), TerrainGeneratorName(want.Terrain))
+				}
+				if g, ok := want.Terrain.(FBMTerrain); ok && got.Terrain != g {
+					t.Errorf("terrain settings %+v, expected %+v", got.Terrain, g)
+				}
+				want.Terrain, got.Terrain = nil, nil
+				if !reflect.DeepEqual(want, got) {
+					t.Errorf("config %+v, expected %+v", got, want)
+				}
+				if !bytes.Equal(testPNG(t, world), testPNG(t, loaded)) {
+					t.Error("the loaded world is not drawn the same")
+				}
+			})
+		}
+	}
+}
+
+// a corrupt save is refused rather than drawn out of bounds
+func TestLoadCorrupt(t *testing.T) {
+	world := testWorld(t, testConfig(4, "quick"))
+	var saved bytes.Buffer
+	if err := SaveJSON(world, &saved); err != nil {
+		t.Fatal(err)
+	}
+	w, h := world.Config.Width, world.Config.Height
+	for _, tc := range []struct {
+		name    string
+		corrupt func(sw *savedWorld)
+	}{
+		{"square terrain", func(sw *savedWorld) { sw.Squares[3][4].Terrain = TERRAIN_LAKE + 1 }},
+		{"square feature", func(sw *savedWorld) { sw.Squares[3][4].Feature = -1 }},
+		{"square biome", func(sw *savedWorld) { sw.Squares[3][4].Biome = len(BIOME_NAMES) }},
+		{"square sea depth", func(sw *savedWorld) { sw.Squares[3][4].SeaDepth = -1 }},
+		{"square flow", func(sw *savedWorld) { sw.Squares[3][4].FlowTo = len(DIR_NEXT) }},
+		{"square country", func(sw *savedWorld) { sw.Squares[3][4].CountryIndex = len(sw.Countries) }},
+		{"river square", func(sw *savedWorld) { sw.Rivers[0].Y[0] = h }},
+		{"river path", func(sw *savedWorld) { sw.Rivers[0].PathStack[0] = len(sw.Rivers[0].Y) }},
+		{"river columns", func(sw *savedWorld) { sw.Rivers[0].X = sw.Rivers[0].X[1:] }},
+		{"city centre", func(sw *savedWorld) { sw.Cities[0].CenterX = w }},
+		{"city square", func(sw *savedWorld) { sw.Cities[0].Y[0] = -1 }},
+		{"city tier", func(sw *savedWorld) { sw.Cities[0].Tier = len(CITY_TIER_NAMES) }},
+		{"country square", func(sw *savedWorld) { sw.Countries[0].X[0] = -1 }},
+		{"country border", func(sw *savedWorld) { sw.Countries[0].BorderY[0] = h }},
+		{"road node", func(sw *savedWorld) { sw.RoadNodes[0].X = w }},
+		{"road square", func(sw *savedWorld) { sw.Roads[0].Y[0] = -1 }},
+		{"port", func(sw *savedWorld) { sw.Ports[0].Y = h }},
+		{"sea lane square", func(sw *savedWorld) { sw.SeaLanes[0].X[0] = w }},
+		{"landmass kind", func(sw *savedWorld) { sw.Landmasses[0].Kind = len(LANDMASS_KIND_NAMES) }},
+		{"landmass label", func(sw *savedWorld) { sw.Landmasses[0].Y = -1 }},
+		{"landmass archipelago", func(sw *savedWorld) { sw.Landmasses[0].Archipelago = len(sw.Archipelagos) }},
+		{"empty archipelago", func(sw *savedWorld) { sw.Archipelagos[0].Landmasses = nil }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sw savedWorld
+			if err := json.Unmarshal(saved.Bytes(), &sw); err != nil {
+				t.Fatal(err)
+			}
+			tc.corrupt(&sw)
+			if _, err := sw.world(); err == nil {
+				t.Error("the corrupt save was loaded")
+			}
+		})
+	}
+}
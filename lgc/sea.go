@@ -0,0 +1,130 @@
+package lgc
+
+import (
+	"context"
+	"sort"
+)
+
+// sea depth classes, from the coast to the abyss; land and lakes have none
+const (
+	SEA_DEPTH_NONE = iota
+	SEA_DEPTH_SHALLOW
+	SEA_DEPTH_SHELF
+	SEA_DEPTH_DEEP
+	SEA_DEPTH_TRENCH
+)
+
+var SEA_DEPTH_NAMES = [...]string{
+	SEA_DEPTH_NONE:    "none",
+	SEA_DEPTH_SHALLOW: "shallow",
+	SEA_DEPTH_SHELF:   "shelf",
+	SEA_DEPTH_DEEP:    "deep",
+	SEA_DEPTH_TRENCH:  "trench",
+}
+
+// blue of each class before the depth within it darkens it
+var SEA_DEPTH_BLUES = [...]int{
+	SEA_DEPTH_SHALLOW: 240,
+	SEA_DEPTH_SHELF:   200,
+	SEA_DEPTH_DEEP:    150,
+	SEA_DEPTH_TRENCH:  95,
+}
+
+const (
+	// values below which the sea is shallow, or may be shelf
+	SEA_SHALLOW_VAL = 64
+	SEA_SHELF_VAL   = 128
+	// the deepest squares of the sea, in percent, are trenches
+	SEA_TRENCH_PCT = 3
+)
+
+// IsShallow is true for the sea a ship can anchor in
+func (st *SquareTerrain) IsShallow() bool {
+	return st.SeaDepth == SEA_DEPTH_SHALLOW
+}
+
+// coastDistances counts the squares from every square to the nearest land
+func coastDistances(grid *Grid) [][]int {
+	return distances(grid, func(y, x int) bool { return isLand(grid.Squares[y][x]) })
+}
+
+// AddSeaDepths sorts the sea into shallow coastal waters, the continental
+// shelf within Magic()/8 squares of the coast, the deep ocean and its trenches
+func (world *World) AddSeaDepths(ctx context.Context) error {
+	grid := world.Grid
+	dist := coastDistances(grid)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var vals []int
+	for y := range grid.Squares {
+		for _, st := range grid.Squares[y] {
+			if st.Terrain == TERRAIN_SEA {
+				vals = append(vals, st.Val)
+			}
+		}
+	}
+	if len(vals) == 0 {
+		return nil
+	}
+	sort.Ints(vals)
+	trench := vals[len(vals)*(100-SEA_TRENCH_PCT)/100]
+	shelf := grid.Magic() / 8
+	if shelf < 2 {
+		shelf = 2
+	}
+
+	counts := make([]int, len(SEA_DEPTH_NAMES))
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			st.SeaDepth = SEA_DEPTH_NONE
+			if st.Terrain != TERRAIN_SEA {
+				continue
+			}
+			d := dist[y][x]
+			switch {
+			case d != -1 && (d <= 1 || (d <= shelf && st.Val < SEA_SHALLOW_VAL)):
+				st.SeaDepth = SEA_DEPTH_SHALLOW
+			case d != -1 && d <= shelf && st.Val < SEA_SHELF_VAL:
+				st.SeaDepth = SEA_DEPTH_SHELF
+			case st.Val >= trench && st.Val > SEA_SHELF_VAL:
+				st.SeaDepth = SEA_DEPTH_TRENCH
+			default:
+				st.SeaDepth = SEA_DEPTH_DEEP
+			}
+			counts[st.SeaDepth]++
+		}
+	}
+	world.logf("sea: %v shallow, %v shelf, %v deep, %v trench", counts[SEA_DEPTH_SHALLOW], counts[SEA_DEPTH_SHELF], counts[SEA_DEPTH_DEEP], counts[SEA_DEPTH_TRENCH])
+	return nil
+}
+
+// seaColor is the blue of the depth class, turning turquoise in warm seas
+// and grey in cold ones
+func seaColor(st *SquareTerrain) (r, g, b uint8) {
+	blue := SEA_DEPTH_BLUES[SEA_DEPTH_DEEP] - st.Val/8
+	if st.SeaDepth != SEA_DEPTH_NONE {
+		blue = SEA_DEPTH_BLUES[st.SeaDepth] - st.Val/8
+	}
+	if blue < 0 {
+		blue = 0
+	}
+	warmth := st.Temperature - 10
+	if warmth > 20 {
+		warmth = 20
+	}
+	if warmth < -20 {
+		warmth = -20
+	}
+	if warmth > 0 {
+		// shallow warm seas are the most turquoise
+		return 0, uint8(warmth * 6 * blue / 255), uint8(blue)
+	}
+	grey := -warmth * 2
+	if blue < grey/2 {
+		// too dark to be tinted
+		return uint8(grey), uint8(grey), uint8(grey)
+	}
+	return uint8(grey), uint8(grey), uint8(blue - grey/2)
+}
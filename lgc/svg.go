@@ -0,0 +1,259 @@
+package lgc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// default look, every element has a class so it can be restyled
+const SVG_STYLE = `
+.terrain { stroke: none; fill-rule: evenodd; }
+.sea { fill: #1e3c8c; }
+.sea.shallow { fill: #4682d2; }
+.sea.shelf { fill: #2d5ab4; }
+.sea.trench { fill: #0f1e5a; }
+.land { fill: #3c8c3c; }
+.mountain { fill: #8c8c8c; }
+.map-border { fill: #969696; }
+.lake { fill: #1e8cc8; }
+.ice-shelf { fill: #cde1f0; }
+.glacier { fill: #ebf0f5; }
+.pole { fill: #f0f5fa; }
+.country { stroke: none; fill-rule: evenodd; fill-opacity: .35; }
+.coast { fill: none; stroke: #0a1e46; stroke-width: .15; stroke-linejoin: round; }
+.country-border { fill: none; stroke: #000; stroke-width: .2; stroke-dasharray: .4 .2; }
+.river { fill: none; stroke: #3c64dc; stroke-width: .3; stroke-linecap: round; stroke-linejoin: round; }
+.road { fill: none; stroke: #96643c; stroke-width: .2; stroke-linecap: round; stroke-linejoin: round; }
+.bridge { fill: none; stroke: #5f4128; stroke-width: .45; }
+.sea-lane { fill: none; stroke: #ebebdc; stroke-width: .15; stroke-dasharray: .6 .4; }
+.port { fill: #ebebdc; stroke: #0a1e46; stroke-width: .08; }
+.city { fill: #c8283c; stroke: #000; stroke-width: .1; }
+.city.capital { fill: #e6b43c; }
+.urban { fill: #a0826e; fill-opacity: .6; stroke: none; }
+.label { font-family: serif; font-style: italic; text-anchor: middle; fill: #000; fill-opacity: .6; }
+.label.continent { font-size: 4px; letter-spacing: .5px; }
+.label.island { font-size: 2px; }
+.label.archipelago { font-size: 2.5px; }
+`
+
+// width of the city symbol of each tier, in squares
+var SVG_CITY_SIZES = [...]float64{
+	CITY_TIER_HAMLET:  .6,
+	CITY_TIER_TOWN:    .8,
+	CITY_TIER_CITY:    1,
+	CITY_TIER_CAPITAL: 1.4,
+}
+
+func ringsPath(rings []ring) string {
+	var b strings.Builder
+	for _, r := range rings {
+		for i, c := range r {
+			if i == 0 {
+				fmt.Fprintf(&b, "M%d %d", c.x, c.y)
+			} else {
+				fmt.Fprintf(&b, "L%d %d", c.x, c.y)
+			}
+		}
+		b.WriteString("Z")
+	}
+	return b.String()
+}
+
+// PrintSVG draws the world as vectors, one grid square being one user unit
+func PrintSVG(world *World, out io.Writer) error {
+	grid := world.Grid
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		grid.Width*grid.SquareWidth, grid.Height*grid.SquareHeight, grid.Width, grid.Height)
+	fmt.Fprintf(w, "<!-- Seed %v -->\n", grid.Seed)
+	fmt.Fprintf(w, "<style>%v</style>\n", SVG_STYLE)
+	fmt.Fprintln(w, `<defs><symbol id="city" viewBox="-1 -1 2 2" overflow="visible"><path d="M-.5 .5V-.1L0 -.5L.5 -.1V.5Z"/></symbol></defs>`)
+
+	fmt.Fprintln(w, `<g id="terrain">`)
+	for _, t := range []struct {
+		terrain int
+		class   string
+	}{
+		{TERRAIN_SEA, "sea"},
+		{TERRAIN_LAND, "land"},
+		{TERRAIN_MOUNTAIN, "mountain"},
+		{TERRAIN_MAP_BORDER, "map-border"},
+		{TERRAIN_LAKE, "lake"},
+	} {
+		rings := traceRings(grid, func(y, x int) bool { return grid.Squares[y][x].Terrain == t.terrain })
+		if len(rings) > 0 {
+			fmt.Fprintf(w, `<path class="terrain %v" d="%v"/>`+"\n", t.class, ringsPath(rings))
+		}
+	}
+	// sea depths over the sea
+	for depth := SEA_DEPTH_SHALLOW; depth < len(SEA_DEPTH_NAMES); depth++ {
+		rings := traceRings(grid, func(y, x int) bool {
+			st := grid.Squares[y][x]
+			return st.Terrain == TERRAIN_SEA && st.SeaDepth == depth
+		})
+		if len(rings) > 0 {
+			fmt.Fprintf(w, `<path class="terrain sea %v" d="%v"/>`+"\n", SEA_DEPTH_NAMES[depth], ringsPath(rings))
+		}
+	}
+	// ice over the terrain it covers
+	for _, t := range []struct {
+		class string
+		in    func(st *SquareTerrain) bool
+	}{
+		{"ice-shelf", func(st *SquareTerrain) bool { return st.IsWater() }},
+		{"glacier", func(st *SquareTerrain) bool { return st.Terrain == TERRAIN_LAND || st.Terrain == TERRAIN_MOUNTAIN }},
+		{"pole", func(st *SquareTerrain) bool { return st.Terrain == TERRAIN_MAP_BORDER }},
+	} {
+		rings := traceRings(grid, func(y, x int) bool {
+			st := grid.Squares[y][x]
+			return st.IsFrozen() && t.in(st)
+		})
+		if len(rings) > 0 {
+			fmt.Fprintf(w, `<path class="terrain %v" d="%v"/>`+"\n", t.class, ringsPath(rings))
+		}
+	}
+	fmt.Fprintln(w, "</g>")
+
+	fmt.Fprintln(w, `<g id="countries">`)
+	if world.Countries != nil {
+		for ic, country := range world.Countries.countries {
+			rings := traceRings(grid, func(y, x int) bool { return grid.Squares[y][x].CountryIndex == ic })
+			if len(rings) > 0 {
+				fmt.Fprintf(w, `<path class="country" id="country-%d" fill="%v" d="%v"/>`+"\n", ic, colorHex(country.Color), ringsPath(rings))
+			}
+		}
+	}
+	fmt.Fprintln(w, "</g>")
+
+	coast := traceRings(grid, func(y, x int) bool { return isLand(grid.Squares[y][x]) })
+	fmt.Fprintf(w, `<path class="coast" d="%v"/>`+"\n", ringsPath(coast))
+
+	// one segment per square side shared with another country, drawn once
+	var borders strings.Builder
+	if world.Countries != nil {
+		for ic, country := range world.Countries.countries {
+			for i := range country.BorderY {
+				y, x := country.BorderY[i], country.BorderX[i]
+				for _, dir := range DIR_NEXT {
+					oy, ox, ok := grid.Neighbour(y, x, dir)
+					if !ok {
+						continue
+					}
+					other := grid.Squares[oy][ox]
+					if other.CountryIndex == ic || (other.CountryIndex != -1 && other.CountryIndex < ic) || !isLand(other) {
+						continue
+					}
+					switch dir {
+					case DIR_NEXT[0]:
+						fmt.Fprintf(&borders, "M%d %dv1", x+1, y)
+					case DIR_NEXT[1]:
+						fmt.Fprintf(&borders, "M%d %dh1", x, y+1)
+					case DIR_NEXT[2]:
+						fmt.Fprintf(&borders, "M%d %dv1", x, y)
+					case DIR_NEXT[3]:
+						fmt.Fprintf(&borders, "M%d %dh1", x, y)
+					}
+				}
+			}
+		}
+	}
+	if borders.Len() > 0 {
+		fmt.Fprintf(w, `<path class="country-border" d="%v"/>`+"\n", borders.String())
+	}
+
+	fmt.Fprintln(w, `<g id="rivers">`)
+	for i, river := range world.Rivers {
+		for _, line := range riverLines(grid.Config, river, GridProjection) {
+			pts := make([]string, len(line))
+			for j, p := range line {
+				pts[j] = fmt.Sprint(p[0], ",", p[1])
+			}
+			fmt.Fprintf(w, `<polyline class="river" data-river="%d" points="%v"/>`+"\n", i, strings.Join(pts, " "))
+		}
+	}
+	fmt.Fprintln(w, "</g>")
+
+	fmt.Fprintln(w, `<g id="roads">`)
+	for i, road := range world.Roads {
+		for _, line := range world.roadLines(road, GridProjection) {
+			pts := make([]string, len(line))
+			for j, p := range line {
+				pts[j] = fmt.Sprint(p[0], ",", p[1])
+			}
+			fmt.Fprintf(w, `<polyline class="road" data-road="%d" points="%v"/>`+"\n", i, strings.Join(pts, " "))
+		}
+	}
+	// a plank across each river, along the road
+	var bridges strings.Builder
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if !st.IsBridge() {
+				continue
+			}
+			across := false
+			for _, dir := range [][2]int{DIR_NEXT[0], DIR_NEXT[2]} {
+				ny, nx, ok := grid.Neighbour(y, x, dir)
+				across = across || (ok && (grid.Squares[ny][nx].Road || grid.Squares[ny][nx].CityIndex != -1))
+			}
+			if across {
+				fmt.Fprintf(&bridges, "M%v %vh.6", float64(x)+.2, float64(y)+.5)
+			} else {
+				fmt.Fprintf(&bridges, "M%v %vv.6", float64(x)+.5, float64(y)+.2)
+			}
+		}
+	}
+	if bridges.Len() > 0 {
+		fmt.Fprintf(w, `<path class="bridge" d="%v"/>`+"\n", bridges.String())
+	}
+	fmt.Fprintln(w, "</g>")
+
+	fmt.Fprintln(w, `<g id="sea-lanes">`)
+	for i, lane := range world.SeaLanes {
+		for _, line := range pathLines(grid.Config, lane.Y, lane.X, GridProjection) {
+			pts := make([]string, len(line))
+			for j, p := range line {
+				pts[j] = fmt.Sprint(p[0], ",", p[1])
+			}
+			fmt.Fprintf(w, `<polyline class="sea-lane" data-sea-lane="%d" points="%v"/>`+"\n", i, strings.Join(pts, " "))
+		}
+	}
+	for i, port := range world.Ports {
+		fmt.Fprintf(w, `<circle class="port" id="port-%d" data-city="%d" cx="%v" cy="%v" r=".3"/>`+"\n",
+			i, port.City, float64(port.X)+.5, float64(port.Y)+.5)
+	}
+	fmt.Fprintln(w, "</g>")
+
+	fmt.Fprintln(w, `<g id="cities">`)
+	for i, city := range world.Cities {
+		if len(city.Y) > 1 {
+			rings := traceRings(grid, func(y, x int) bool { return grid.Squares[y][x].CityIndex == i })
+			fmt.Fprintf(w, `<path class="urban" data-city="%d" d="%v"/>`+"\n", i, ringsPath(rings))
+		}
+	}
+	for i, city := range world.Cities {
+		size := SVG_CITY_SIZES[city.Tier]
+		fmt.Fprintf(w, `<use class="city %v" href="#city" id="city-%d" data-population="%d" x="%v" y="%v" width="%v" height="%v"/>`+"\n",
+			city.TierName(), i, city.Population, float64(city.CenterX)+(1-size)/2, float64(city.CenterY)+(1-size)/2, size, size)
+	}
+	fmt.Fprintln(w, "</g>")
+
+	fmt.Fprintln(w, `<g id="labels">`)
+	for i, lm := range world.Landmasses {
+		if lm.Name != "" {
+			fmt.Fprintf(w, `<text class="label %v" data-landmass="%d" x="%v" y="%v">%v</text>`+"\n",
+				lm.KindName(), i, float64(lm.X)+.5, float64(lm.Y)+.5, lm.Name)
+		}
+	}
+	for i, a := range world.Archipelagos {
+		// under its largest island
+		lm := world.Landmasses[a.Landmasses[0]]
+		fmt.Fprintf(w, `<text class="label archipelago" data-archipelago="%d" x="%v" y="%v">%v</text>`+"\n",
+			i, float64(lm.X)+.5, float64(lm.Y)+3, a.Name)
+	}
+	fmt.Fprintln(w, "</g>")
+
+	fmt.Fprintln(w, "</svg>")
+	return w.Flush()
+}
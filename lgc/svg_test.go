@@ -0,0 +1,110 @@
+package lgc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// the SVG is well formed, has one element for each country, city and port,
+// and no country border on the sides of the map that are not connected
+func TestPrintSVG(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		terrain            string
+		connectY, connectX bool
+	}{
+		{"flat", "quick", false, false},
+		{"cylinder", "fbm", false, true},
+		{"torus", "plates", true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := testConfig(5, tc.terrain)
+			cfg.ConnectY, cfg.ConnectX = tc.connectY, tc.connectX
+			world := testWorld(t, cfg)
+			var buf bytes.Buffer
+			if err := PrintSVG(world, &buf); err != nil {
+				t.Fatal(err)
+			}
+
+			classes := make(map[string]int)
+			var root string
+			dec := xml.NewDecoder(&buf)
+			for {
+				tok, err := dec.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				el, ok := tok.(xml.StartElement)
+				if !ok {
+					continue
+				}
+				if root == "" {
+					root = el.Name.Local
+				}
+				class := strings.Fields(attr(el, "class"))
+				if len(class) == 0 {
+					continue
+				}
+				classes[class[0]]++
+				if class[0] == "country-border" {
+					checkBorders(t, cfg, attr(el, "d"))
+				}
+			}
+			if root != "svg" {
+				t.Errorf("root element %q, expected svg", root)
+			}
+			for _, want := range []struct {
+				class string
+				n     int
+			}{
+				{"coast", 1},
+				{"country", world.Countries.CountryCount()},
+				{"city", len(world.Cities)},
+				{"port", len(world.Ports)},
+			} {
+				if classes[want.class] != want.n {
+					t.Errorf("%v elements of class %v, expected %v", classes[want.class], want.class, want.n)
+				}
+			}
+		})
+	}
+}
+
+func attr(el xml.StartElement, name string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// checkBorders wants every unit segment of d inside the map, and off its
+// sides that are not connected
+func checkBorders(t *testing.T, cfg *Config, d string) {
+	t.Helper()
+	for _, seg := range strings.Split(d, "M")[1:] {
+		var x, y int
+		var dir byte
+		if _, err := fmt.Sscanf(seg, "%d %d%c1", &x, &y, &dir); err != nil {
+			t.Fatalf("border segment %q: %v", seg, err)
+		}
+		vertical := dir == 'v'
+		if x < 0 || x > cfg.Width || y < 0 || y > cfg.Height {
+			t.Errorf("border segment %q off the map", seg)
+		}
+		if vertical && !cfg.ConnectX && (x == 0 || x == cfg.Width) {
+			t.Errorf("border segment %q on the side at x=%v", seg, x)
+		}
+		if !vertical && !cfg.ConnectY && (y == 0 || y == cfg.Height) {
+			t.Errorf("border segment %q on the side at y=%v", seg, y)
+		}
+	}
+}
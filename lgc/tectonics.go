@@ -0,0 +1,208 @@
+package lgc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+)
+
+// crust heights and what collisions and rifts do to them
+const (
+	CRUST_CONTINENTAL float64 = .4
+	CRUST_OCEANIC     float64 = -.6
+	// new crust along a divergent boundary, a ridge in the sea or a valley on land
+	CRUST_RIDGE float64 = -.4
+	CRUST_RIFT  float64 = -.2
+	// uplift of the overriding crust at a convergent boundary
+	UPLIFT_COLLISION  float64 = .15
+	UPLIFT_SUBDUCTION float64 = .08
+	UPLIFT_ARC        float64 = .04
+	// uplift above which a square is a mountain
+	UPLIFT_MOUNTAIN float64 = .08
+)
+
+type plate struct {
+	vy, vx      int
+	period      int
+	continental bool
+}
+
+// TectonicTerrain seeds plates, moves them and piles crust up where they collide
+type TectonicTerrain struct {
+	// Magic()/2 plates and Magic()/2 steps if zero
+	Plates int
+	Steps  int
+	// share of continental plates in percent, 40 if zero
+	ContinentalPct int
+}
+
+func (g TectonicTerrain) GenerateTerrain(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error) {
+	terrain, _, err := g.GenerateMountains(ctx, cfg, rng)
+	return terrain, err
+}
+
+func (g TectonicTerrain) GenerateMountains(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, [][]bool, error) {
+	if g.Plates == 0 {
+		g.Plates = cfg.Magic() / 2
+	}
+	if g.Steps == 0 {
+		g.Steps = cfg.Magic() / 2
+	}
+	if g.ContinentalPct == 0 {
+		g.ContinentalPct = 40
+	}
+	cfg.logf("tectonic terrain %vx%v, %v plates, %v steps", cfg.Width, cfg.Height, g.Plates, g.Steps)
+
+	plates := make([]plate, g.Plates)
+	plateY, plateX := make([]int, g.Plates), make([]int, g.Plates)
+	for i := range plates {
+		plateY[i], plateX[i] = rng.Intn(cfg.Height), rng.Intn(cfg.Width)
+		dir := DIR_SQUARE[rng.Intn(len(DIR_SQUARE))]
+		plates[i] = plate{
+			vy:          dir[0],
+			vx:          dir[1],
+			period:      1 + rng.Intn(3),
+			continental: rng.Intn(100) < g.ContinentalPct,
+		}
+	}
+
+	// voronoi cells, with the crust of their plate
+	owner := make([][]int, cfg.Height)
+	crust, uplift := newHeights(cfg), newHeights(cfg)
+	for y := range owner {
+		owner[y] = make([]int, cfg.Width)
+		for x := range owner[y] {
+			best := math.Inf(1)
+			for i := range plates {
+				dy, dx := cfg.Offset(y, x, plateY[i], plateX[i])
+				if d := math.Hypot(float64(dy), float64(dx)); d < best {
+					best, owner[y][x] = d, i
+				}
+			}
+			if plates[owner[y][x]].continental {
+				crust[y][x] = CRUST_CONTINENTAL
+			} else {
+				crust[y][x] = CRUST_OCEANIC
+			}
+		}
+	}
+
+	newOwner := make([][]int, cfg.Height)
+	newCrust, newUplift := newHeights(cfg), newHeights(cfg)
+	for y := range newOwner {
+		newOwner[y] = make([]int, cfg.Width)
+	}
+	for step := 0; step < g.Steps; step++ {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		cfg.report(STAGE_TERRAIN, step, g.Steps)
+		for y := range newOwner {
+			for x := range newOwner[y] {
+				newOwner[y][x] = -1
+			}
+		}
+
+		// move every square of the plates moving this step
+		for _, y := range rng.Perm(cfg.Height) {
+			for _, x := range rng.Perm(cfg.Width) {
+				p := plates[owner[y][x]]
+				ty, tx := y, x
+				if step%p.period == 0 {
+					ty, tx = y+p.vy, x+p.vx
+					if (!cfg.ConnectY && (ty < 0 || ty >= cfg.Height)) || (!cfg.ConnectX && (tx < 0 || tx >= cfg.Width)) {
+						// off the map
+						continue
+					}
+					ty, tx = cfg.Inside(ty, tx)
+				}
+				if newOwner[ty][tx] == -1 {
+					newOwner[ty][tx], newCrust[ty][tx], newUplift[ty][tx] = owner[y][x], crust[y][x], uplift[y][x]
+					continue
+				}
+				if newOwner[ty][tx] == owner[y][x] {
+					continue
+				}
+
+				// convergent boundary, the lighter crust stays on top
+				c, u := crust[y][x], uplift[y][x]
+				if c < newCrust[ty][tx] {
+					c, u = newCrust[ty][tx], newUplift[ty][tx]
+				} else {
+					newOwner[ty][tx] = owner[y][x]
+				}
+				other := newCrust[ty][tx] + crust[y][x] - c
+				switch {
+				case c > 0 && other > 0:
+					u += UPLIFT_COLLISION
+				case c > 0:
+					u += UPLIFT_SUBDUCTION
+				default:
+					u += UPLIFT_ARC
+				}
+				newCrust[ty][tx], newUplift[ty][tx] = c, u
+			}
+		}
+
+		// divergent boundaries, gaps are filled with new crust of a neighbouring plate
+		for y := range newOwner {
+			for x := range newOwner[y] {
+				if newOwner[y][x] != -1 {
+					continue
+				}
+				newCrust[y][x], newUplift[y][x] = CRUST_RIDGE, 0
+				for _, dir := range rng.Perm(len(DIR_NEXT)) {
+					ny, nx := cfg.Inside(y+DIR_NEXT[dir][0], x+DIR_NEXT[dir][1])
+					if o := newOwner[ny][nx]; o != -1 {
+						newOwner[y][x] = o
+						if newCrust[ny][nx] > 0 {
+							newCrust[y][x] = CRUST_RIFT
+						}
+						break
+					}
+				}
+				if newOwner[y][x] == -1 {
+					newOwner[y][x] = owner[y][x]
+				}
+			}
+		}
+		owner, newOwner = newOwner, owner
+		crust, newCrust = newCrust, crust
+		uplift, newUplift = newUplift, uplift
+	}
+
+	// boundaries are one square wide, ranges are wider
+	radius := cfg.Magic() / 12
+	if radius < 1 {
+		radius = 1
+	}
+	ranges := newHeights(cfg)
+	for y := range ranges {
+		for x := range ranges[y] {
+			for dy := -radius; dy <= radius; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					ny, nx := cfg.Inside(y+dy, x+dx)
+					ranges[y][x] += uplift[ny][nx]
+				}
+			}
+			ranges[y][x] /= float64((2*radius + 1) * (2*radius + 1))
+		}
+	}
+
+	// ragged coasts and a little relief everywhere
+	heights, err := fbm(ctx, cfg, rng, 5, .5)
+	if err != nil {
+		return nil, nil, err
+	}
+	mountains := make([][]bool, cfg.Height)
+	for y := range heights {
+		mountains[y] = make([]bool, cfg.Width)
+		for x := range heights[y] {
+			// piles of crust get wider rather than higher
+			heights[y][x] = crust[y][x] + math.Sqrt(ranges[y][x]) + heights[y][x]/3
+			mountains[y][x] = ranges[y][x] >= UPLIFT_MOUNTAIN
+		}
+	}
+	cfg.report(STAGE_TERRAIN, 1, 1)
+	return heightsToTerrain(cfg, heights), mountains, nil
+}
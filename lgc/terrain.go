@@ -0,0 +1,241 @@
+package lgc
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+)
+
+var (
+	DIRECTIONS [20][2]int = [20][2]int{
+		{0, 1}, {0, -1}, {1, 0}, {-1, 0},
+		{-1, -1}, {-1, 1}, {1, -1}, {1, 1},
+		{0, 2}, {0, -2}, {2, 0}, {-2, 0},
+		{-2, -1}, {-2, 1}, {-1, 2}, {1, 2},
+		{2, 1}, {2, -1}, {1, -2}, {-1, -2},
+	}
+)
+
+func Abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func Sign(x int) int {
+	if x > 0 {
+		return 1
+	} else if x < 0 {
+		return -1
+	}
+	return 0
+}
+
+func NewSquares(cfg *Config) [][]int {
+	squares := make([][]int, cfg.Height)
+	for y := range squares {
+		squares[y] = make([]int, cfg.Width)
+	}
+	return squares
+}
+
+func GenerateTerrain(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error) {
+	squares := NewSquares(cfg)
+	maxVal := cfg.MaxVal()
+	cfg.logf("terrain %vx%v, %v frames", cfg.Width, cfg.Height, cfg.Frames)
+
+	// spawn
+	newY, newX := rng.Intn(cfg.Height), rng.Intn(cfg.Width)
+	for i := 0; i < cfg.SpawnPower; i++ {
+		if squares[newY][newX] == 0 {
+			squares[newY][newX] = maxVal
+		}
+		newY, newX = cfg.Inside(newY+rng.Intn(maxVal), newX+rng.Intn(maxVal))
+	}
+
+	// main loop on frames
+	sim := newTerrainSim(cfg, squares, runtime.GOMAXPROCS(0))
+	defer sim.stop()
+	for frame := 1; frame <= cfg.Frames; frame++ {
+		// loop on squares
+		cfg.logf("frame %v/%v", frame, cfg.Frames)
+		cfg.report(STAGE_TERRAIN, frame-1, cfg.Frames)
+		for _, y := range rng.Perm(cfg.Height) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			for _, x := range rng.Perm(cfg.Width) {
+				sim.moveSquare(rng, y, x)
+			}
+		}
+	}
+	cfg.report(STAGE_TERRAIN, cfg.Frames, cfg.Frames)
+
+	return squares, nil
+}
+
+// squares move one after the other, each move depending on the previous ones,
+// so only the neighbourhood scan of a single square is shared between workers:
+// each worker reads a band of rows while squares are left untouched
+type terrainSim struct {
+	cfg     *Config
+	squares [][]int
+	workers int
+	jobs    chan forceBand
+	results chan [2]int
+}
+
+type forceBand struct {
+	y, x, val, dist int
+	y0, y1          int
+}
+
+// a band smaller than this is not worth a worker
+const MIN_FORCE_BAND int = 8
+
+func newTerrainSim(cfg *Config, squares [][]int, workers int) *terrainSim {
+	sim := &terrainSim{
+		cfg:     cfg,
+		squares: squares,
+		workers: workers,
+		jobs:    make(chan forceBand, workers),
+		results: make(chan [2]int, workers),
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for band := range sim.jobs {
+				dy, dx := sim.bandForce(band)
+				sim.results <- [2]int{dy, dx}
+			}
+		}()
+	}
+	return sim
+}
+
+func (sim *terrainSim) stop() {
+	close(sim.jobs)
+}
+
+// force sums the offsets of the squares of the same sign within dist
+func (sim *terrainSim) force(y, x, dist int) (dy, dx int) {
+	val := sim.squares[y][x]
+	rows := 2*dist + 1
+	n := rows / MIN_FORCE_BAND
+	if n > sim.workers {
+		n = sim.workers
+	}
+	if n <= 1 {
+		return sim.bandForce(forceBand{y, x, val, dist, y - dist, y + dist + 1})
+	}
+	for i := 0; i < n; i++ {
+		sim.jobs <- forceBand{y, x, val, dist, y - dist + i*rows/n, y - dist + (i+1)*rows/n}
+	}
+	for i := 0; i < n; i++ {
+		r := <-sim.results
+		dy += r[0]
+		dx += r[1]
+	}
+	return
+}
+
+func (sim *terrainSim) bandForce(band forceBand) (dy, dx int) {
+	y, x, dist := band.y, band.x, band.dist
+	for yo := band.y0; yo < band.y1; yo++ {
+		for xo := x - dist; xo <= x+dist; xo++ {
+			// yo, xo inside
+			inyo, inxo := sim.cfg.Inside(yo, xo)
+
+			// skip empty and far away
+			if sim.squares[inyo][inxo]*band.val <= 0 || (yo-y)*(yo-y)+(xo-x)*(xo-x) >= dist*dist {
+				continue
+			}
+
+			// count force
+			if sim.cfg.ConnectY || inyo == yo {
+				dy += (yo - y)
+			}
+			if sim.cfg.ConnectX || inxo == xo {
+				dx += (xo - x)
+			}
+		}
+	}
+	return
+}
+
+func (sim *terrainSim) moveSquare(rng *rand.Rand, y, x int) {
+	cfg, squares := sim.cfg, sim.squares
+	maxVal := cfg.MaxVal()
+
+	// skip empty
+	if squares[y][x] == 0 {
+		return
+	}
+
+	// correct excess negatives
+	if squares[y][x] <= -maxVal {
+		squares[y][x] = 1 - maxVal
+	}
+
+	// move to other
+	dist := ((squares[y][x]*2 + maxVal) % maxVal)
+	dy, dx := sim.force(y, x, dist)
+	ry, rx := Abs(dy), Abs(dx)
+	if squares[y][x] < 0 {
+		dy /= -squares[y][x]
+		dx /= -squares[y][x]
+	} else if dist > 0 {
+		dy /= dist
+		dx /= dist
+	}
+	nextY, nextX := cfg.Inside(y+dy, x+dx)
+	for (dy != 0 || dx != 0) && squares[nextY][nextX]*squares[y][x] > 0 {
+		if rng.Intn(ry+rx) < ry {
+			dy -= Sign(dy)
+		} else {
+			dx -= Sign(dx)
+		}
+		nextY, nextX = cfg.Inside(y+dy, x+dx)
+	}
+	if squares[nextY][nextX]*squares[y][x] <= 0 {
+		squares[nextY][nextX], squares[y][x] = squares[y][x]-Sign(squares[y][x]), squares[nextY][nextX]-Sign(squares[y][x])
+		return
+	}
+
+	// move randomly
+	for _, dir := range rng.Perm(len(DIRECTIONS)) {
+		nextY, nextX = cfg.Inside(y+DIRECTIONS[dir][0], x+DIRECTIONS[dir][1])
+		if squares[nextY][nextX]*squares[y][x] <= 0 {
+			squares[nextY][nextX], squares[y][x] = squares[y][x]+Sign(squares[y][x]), squares[nextY][nextX]-Sign(squares[y][x])
+			return
+		}
+	}
+}
+
+func GenerateTerrainQuick(ctx context.Context, cfg *Config, rng *rand.Rand) ([][]int, error) {
+	squares := NewSquares(cfg)
+	for y := range squares {
+		for x := range squares[y] {
+			squares[y][x] = rng.Intn(len(DIRECTIONS)) - len(DIRECTIONS)*50/100
+		}
+	}
+	cfg.logf("quick terrain %vx%v", cfg.Width, cfg.Height)
+	for i, y := range rng.Perm(len(squares)) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cfg.report(STAGE_TERRAIN, i, len(squares))
+		for _, x := range rng.Perm(len(squares[y])) {
+			var s int
+			for _, dir := range DIRECTIONS {
+				yo, xo := cfg.Inside(y+dir[0], x+dir[1])
+				s += Sign(squares[yo][xo])
+			}
+			squares[y][x] += s
+			squares[y][x] /= 2
+		}
+	}
+	cfg.report(STAGE_TERRAIN, 1, 1)
+
+	return squares, nil
+}
@@ -0,0 +1,67 @@
+package lgc
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// the particle terrain only depends on the seed, whatever the number of workers;
+// run with -race to check they do not step on each other
+func TestTerrainAnyWorkers(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, tc := range []struct {
+		name               string
+		connectY, connectX bool
+	}{
+		{"flat", false, false},
+		{"cylinder", false, true},
+		{"torus", true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var want [][]int
+			for _, procs := range []int{1, 2, 3, 8} {
+				runtime.GOMAXPROCS(procs)
+				cfg := testConfig(7, "particles")
+				cfg.ConnectY, cfg.ConnectX = tc.connectY, tc.connectX
+				cfg.Frames = 4
+				got, err := GenerateTerrain(context.Background(), cfg, cfg.NewRand())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want == nil {
+					want = got
+				} else if !reflect.DeepEqual(got, want) {
+					t.Errorf("%v workers gave another terrain than 1", procs)
+				}
+			}
+		})
+	}
+}
+
+// the force summed over bands by the workers is the one of a single band
+func TestForceBands(t *testing.T) {
+	cfg := testConfig(8, "particles")
+	cfg.ConnectX = true
+	squares, err := GenerateTerrainQuick(context.Background(), cfg, cfg.NewRand())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{1, 2, 5} {
+		sim := newTerrainSim(cfg, squares, workers)
+		for _, tc := range []struct{ y, x, dist int }{
+			{0, 0, 3},
+			{10, 20, 8},
+			{16, 63, 12},
+			{31, 5, cfg.MaxVal() - 1},
+		} {
+			dy, dx := sim.force(tc.y, tc.x, tc.dist)
+			band := forceBand{tc.y, tc.x, squares[tc.y][tc.x], tc.dist, tc.y - tc.dist, tc.y + tc.dist + 1}
+			if wy, wx := sim.bandForce(band); dy != wy || dx != wx {
+				t.Errorf("%v workers, force at %v,%v within %v: %v,%v, expected %v,%v", workers, tc.y, tc.x, tc.dist, dy, dx, wy, wx)
+			}
+		}
+		sim.stop()
+	}
+}
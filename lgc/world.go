@@ -0,0 +1,828 @@
+package lgc
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// World is a generated map with everything found on it
+type World struct {
+	*Config
+	Grid          *Grid
+	Rivers        []*River
+	Cities        []*City
+	Countries     *CountryGroup
+	NbLand, NbSea int
+	NbLake        int
+	Landmasses    []*Landmass
+	Archipelagos  []*Archipelago
+	Roads         []*Road
+	RoadNodes     []*RoadNode
+	Ports         []*Port
+	SeaLanes      []*SeaLane
+	// mountains given by the terrain generator, lowest land if nil
+	MountainMask [][]bool
+	rng          *rand.Rand
+}
+
+// Generate runs every stage on a new world, until done or ctx is cancelled
+func Generate(ctx context.Context, cfg *Config) (*World, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	rng := cfg.NewRand()
+	gen := cfg.Terrain
+	if gen == nil {
+		gen = TerrainFunc(GenerateTerrain)
+	}
+	var terrain [][]int
+	var mask [][]bool
+	var err error
+	if mg, ok := gen.(MountainGenerator); ok {
+		terrain, mask, err = mg.GenerateMountains(ctx, cfg, rng)
+	} else {
+		terrain, err = gen.GenerateTerrain(ctx, cfg, rng)
+	}
+	if err != nil {
+		return nil, err
+	}
+	world := NewWorld(cfg, rng, terrain)
+	world.MountainMask = mask
+	if err := world.AddFeatures(ctx); err != nil {
+		return nil, err
+	}
+	if err := world.runStage(ctx, STAGE_DECORATION, world.Decorate); err != nil {
+		return nil, err
+	}
+	return world, nil
+}
+
+// AddFeaturesToTerrain runs the feature stages in order on a terrain made by a terrain generator
+func AddFeaturesToTerrain(ctx context.Context, cfg *Config, rng *rand.Rand, terrain [][]int) (*World, error) {
+	world := NewWorld(cfg, rng, terrain)
+	if err := world.AddFeatures(ctx); err != nil {
+		return nil, err
+	}
+	return world, nil
+}
+
+// AddFeatures runs the feature stages in order
+func (world *World) AddFeatures(ctx context.Context) error {
+	for _, stage := range []struct {
+		stage int
+		run   func(context.Context) error
+	}{
+		{STAGE_ISOLATED, world.DeleteIsolated},
+		{STAGE_SEA_LEVEL, world.SetSeaLevel},
+		{STAGE_LAND_AND_SEA, world.SplitLandAndSea},
+		{STAGE_SMOOTH, world.Smooth},
+		{STAGE_EROSION, world.Erode},
+		{STAGE_MOUNTAINS, world.AddMountains},
+		{STAGE_LAKES, world.AddLakes},
+		{STAGE_SEA_DEPTHS, world.AddSeaDepths},
+		{STAGE_LANDMASSES, world.AddLandmasses},
+		{STAGE_CLIMATE, world.AddClimate},
+		{STAGE_RIVERS, world.AddRivers},
+		{STAGE_CITIES, world.AddCities},
+		{STAGE_COUNTRIES, world.AddCountries},
+		{STAGE_ROADS, world.AddRoads},
+		{STAGE_SEA_LANES, world.AddSeaLanes},
+		{STAGE_MAP_BORDERS, world.AddMapBorders},
+		{STAGE_COLORS, world.Colorize},
+	} {
+		if err := world.runStage(ctx, stage.stage, stage.run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (world *World) runStage(ctx context.Context, stage int, run func(context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	world.report(stage, 0, 1)
+	if err := run(ctx); err != nil {
+		return err
+	}
+	world.report(stage, 1, 1)
+	return nil
+}
+
+// NewWorld is the inner model of a terrain, before any stage is run
+func NewWorld(cfg *Config, rng *rand.Rand, terrain [][]int) *World {
+	grid := NewGrid(cfg)
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			grid.Squares[y][x] = NewSquareTerrain(cfg, terrain[y][x])
+		}
+	}
+	return &World{
+		Config: cfg,
+		Grid:   grid,
+		rng:    rng,
+	}
+}
+
+// DeleteIsolated flips the sign of squares surrounded by the opposite sign
+func (world *World) DeleteIsolated(ctx context.Context) error {
+	grid := world.Grid
+	done := false
+	for !done {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		done = true
+		for y := range grid.Squares {
+			for x := range grid.Squares[y] {
+				surroundings := 0
+				for _, dir := range DIRECTIONS {
+					nhbY, nhbX := grid.Inside(y+dir[0], x+dir[1])
+					surroundings += grid.Squares[nhbY][nhbX].Val
+				}
+				if surroundings*grid.Squares[y][x].Val < 0 {
+					grid.Squares[y][x].Val *= -1
+					done = false
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SetSeaLevel raises or lowers the sea until LandPct of the squares are land,
+// the level being picked on the blurred value histogram as close to 0 as it
+// allows; land and sea are then stretched back to their extremes so the coast
+// stays where smoothing expects it
+func (world *World) SetSeaLevel(ctx context.Context) error {
+	grid := world.Grid
+	if grid.LandPct == 0 {
+		return nil
+	}
+	// the values are blurred first, or the new coast would go through
+	// every bit of noise close to the level and leave the sea full of islets;
+	// values are few on small maps, so squares are ranked by their blurred
+	// sum and then by their own value, which leaves far fewer ties
+	rank := 2*grid.MaxVal() + 1
+	blurred := NewSquares(grid.Config)
+	vals := make([]int, 0, grid.Surface())
+	min, max := 0, 1
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			sum := st.Val
+			for _, dir := range DIR_SQUARE {
+				nhbY, nhbX := grid.Inside(y+dir[0], x+dir[1])
+				sum += grid.Squares[nhbY][nhbX].Val
+			}
+			if avg := sum / (len(DIR_SQUARE) + 1); avg < min {
+				min = avg
+			} else if avg > max {
+				max = avg
+			}
+			blurred[y][x] = sum*rank + st.Val
+			vals = append(vals, blurred[y][x])
+		}
+	}
+	sort.Ints(vals)
+	lowest, highest := vals[0], vals[len(vals)-1]
+
+	// land is above the level, every value found but the highest is a
+	// candidate, so some land and some sea are left whatever the target
+	target := len(vals) * grid.LandPct / 100
+	level, best := lowest-1, len(vals)+1
+	for i, v := range vals[:len(vals)-1] {
+		if vals[i+1] == v {
+			continue
+		}
+		if v < 0 && vals[i+1] > 0 {
+			// 0 is between this value and the next one
+			v = 0
+		}
+		miss := Abs(len(vals) - 1 - i - target)
+		if miss < best || (miss == best && Abs(v) < Abs(level)) {
+			level, best = v, miss
+		}
+	}
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if v := blurred[y][x]; v > level {
+				if highest > level+1 {
+					st.Val = 1 + (v-level-1)*(max-1)/(highest-level-1)
+				} else {
+					st.Val = 1
+				}
+			} else if lowest < level {
+				st.Val = (v - level) * min / (lowest - level)
+			} else {
+				st.Val = 0
+			}
+		}
+	}
+	world.logf("sea level: %v", level/rank/(len(DIR_SQUARE)+1))
+	return nil
+}
+
+// SplitLandAndSea sets the terrain to land or sea, sea values become positive;
+// the highest square is land and the lowest is sea whatever their values, so
+// no map is all land or all sea
+func (world *World) SplitLandAndSea(ctx context.Context) error {
+	grid := world.Grid
+	hy, hx, ly, lx := 0, 0, 0, 0
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if st.Val > grid.Squares[hy][hx].Val {
+				hy, hx = y, x
+			}
+			if st.Val <= grid.Squares[ly][lx].Val {
+				ly, lx = y, x
+			}
+		}
+	}
+	if grid.Squares[hy][hx].Val <= 0 {
+		grid.Squares[hy][hx].Val = 1
+	}
+	if grid.Squares[ly][lx].Val > 0 {
+		grid.Squares[ly][lx].Val = 0
+	}
+	world.NbLand, world.NbSea = 0, 0
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			if grid.Squares[y][x].Val <= 0 {
+				grid.Squares[y][x].Terrain = TERRAIN_SEA
+				grid.Squares[y][x].Val *= -1
+				world.NbSea++
+			} else {
+				grid.Squares[y][x].Terrain = TERRAIN_LAND
+				world.NbLand++
+			}
+		}
+	}
+	world.logf("land: %v, sea: %v, land%%: %v", world.NbLand, world.NbSea, world.NbLand*100/grid.Surface())
+	return nil
+}
+
+// Smooth averages values with their surroundings and normalizes them to 255
+func (world *World) Smooth(ctx context.Context) error {
+	grid := world.Grid
+	minL, maxL, minS, maxS := -1, -1, -1, -1
+	for i, y := range world.rng.Perm(grid.Height) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		world.report(STAGE_SMOOTH, i, grid.Height)
+		for _, x := range world.rng.Perm(grid.Width) {
+			for dir := range DIRECTIONS {
+				nhbY, nhbX := grid.Inside(y+DIRECTIONS[dir][0], x+DIRECTIONS[dir][1])
+				grid.Squares[y][x].Val += grid.Squares[nhbY][nhbX].Val
+			}
+			grid.Squares[y][x].Val /= 1 + len(DIRECTIONS)
+
+			// normalize
+			if grid.Squares[y][x].Val >= grid.MaxVal() {
+				grid.Squares[y][x].Val = grid.MaxVal() - 1
+			}
+
+			// min and max land
+			if grid.Squares[y][x].Terrain == TERRAIN_LAND {
+				if minL == -1 || minL > grid.Squares[y][x].Val {
+					minL = grid.Squares[y][x].Val
+				}
+				if maxL == -1 || maxL < grid.Squares[y][x].Val {
+					maxL = grid.Squares[y][x].Val
+				}
+			}
+
+			// min and max sea
+			if grid.Squares[y][x].Terrain == TERRAIN_SEA {
+				if minS == -1 || minS > grid.Squares[y][x].Val {
+					minS = grid.Squares[y][x].Val
+				}
+				if maxS == -1 || maxS < grid.Squares[y][x].Val {
+					maxS = grid.Squares[y][x].Val
+				}
+			}
+		}
+	}
+
+	if maxL == -1 {
+		return fmt.Errorf("no land to smooth")
+	}
+	if maxS == -1 {
+		return fmt.Errorf("no sea to smooth")
+	}
+
+	// normalize values to 255, a few islets may have been flattened to 0
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			if grid.Squares[y][x].Terrain == TERRAIN_LAND && maxL > 0 {
+				grid.Squares[y][x].Val = grid.Squares[y][x].Val * 255 / maxL
+			} else if grid.Squares[y][x].Terrain == TERRAIN_SEA && maxS > 0 {
+				grid.Squares[y][x].Val = grid.Squares[y][x].Val * 255 / maxS
+			}
+		}
+	}
+	return nil
+}
+
+// AddMountains turns the land under MountainMask, or else the lowest 5% of land, into mountains
+func (world *World) AddMountains(ctx context.Context) error {
+	grid := world.Grid
+
+	if world.MountainMask != nil {
+		n := 0
+		for y := range grid.Squares {
+			for x := range grid.Squares[y] {
+				if world.MountainMask[y][x] && grid.Squares[y][x].Terrain == TERRAIN_LAND {
+					grid.Squares[y][x].Terrain = TERRAIN_MOUNTAIN
+					n++
+				}
+			}
+		}
+		world.logf("mountains: %v", n)
+		if n > 0 {
+			return nil
+		}
+	}
+
+	// elevation map
+	var elevation [256]int
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			if grid.Squares[y][x].Terrain == TERRAIN_LAND {
+				elevation[grid.Squares[y][x].Val]++
+			}
+		}
+	}
+
+	// mountains
+	var se, maxEl int
+	for el, count := range elevation {
+		se += count
+		if se >= world.NbLand*5/100 {
+			maxEl = el
+			break
+		}
+	}
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			if grid.Squares[y][x].Terrain == TERRAIN_LAND && grid.Squares[y][x].Val <= maxEl {
+				grid.Squares[y][x].Terrain = TERRAIN_MOUNTAIN
+			}
+		}
+	}
+	return nil
+}
+
+// AddRivers walks rivers from mountains to the sea until RiverPct of land is covered
+func (world *World) AddRivers(ctx context.Context) error {
+	grid := world.Grid
+	if grid.RiverMode == RIVER_MODE_FLOW {
+		return world.AddFlowRivers(ctx)
+	}
+	// too little land for any mountain
+	if grid.Count(func(st *SquareTerrain) bool { return st.Terrain == TERRAIN_MOUNTAIN }) == 0 {
+		world.logf("0 rivers: 0")
+		return nil
+	}
+	// a river reaching a lake goes on from its outlet, the lowest square of
+	// its shore, as the water does in flow mode
+	lakes, lakeSizes := components(grid, func(y, x int) bool {
+		return grid.Squares[y][x].Terrain == TERRAIN_LAKE
+	})
+	outlets := make([][2]int, len(lakeSizes))
+	for l := range outlets {
+		outlets[l] = [2]int{-1, -1}
+	}
+	for y := range grid.Squares {
+		for x, st := range grid.Squares[y] {
+			if st.IsWater() {
+				continue
+			}
+			for _, dir := range DIR_NEXT {
+				ny, nx, ok := grid.Neighbour(y, x, dir)
+				if !ok || lakes[ny][nx] == -1 {
+					continue
+				}
+				o := &outlets[lakes[ny][nx]]
+				if o[0] == -1 || st.Val > grid.Squares[o[0]][o[1]].Val {
+					*o = [2]int{y, x}
+				}
+			}
+		}
+	}
+	drained := make([]bool, len(lakeSizes))
+	// the lake each outflow comes from
+	outOf := make(map[*River]int)
+
+	river, err := NewRiver(grid, world.rng)
+	if err != nil {
+		return err
+	}
+	rivers := []*River{river}
+	var riverSurface int
+	for riverSurface < world.NbLand*grid.RiverPct/100 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		river := rivers[len(rivers)-1]
+		// for each river not at sea yet, decide where to go
+		highDir, highLevel := -1, river.Level
+		end, endLake := false, -1
+		for _, dir := range world.rng.Perm(len(DIR_NEXT)) {
+			nhbY, nhbX := grid.Inside(river.Y()+DIR_NEXT[dir][0], river.X()+DIR_NEXT[dir][1])
+			if river.WasAt(nhbY, nhbX) {
+				continue
+			}
+			tight := false
+			for _, diro := range DIR_NEXT {
+				onY, onX := grid.Inside(nhbY+diro[0], nhbX+diro[1])
+				if (onY != river.Y() || onX != river.X()) && river.IsAt(onY, onX) {
+					tight = true
+					break
+				}
+			}
+			if tight {
+				continue
+			}
+			if l, ok := outOf[river]; ok && lakes[nhbY][nhbX] == l {
+				// not back into its own lake
+				continue
+			}
+			if grid.Squares[nhbY][nhbX].Feature == FEATURE_RIVER || grid.Squares[nhbY][nhbX].IsWater() {
+				end, endLake = true, lakes[nhbY][nhbX]
+				break
+			}
+			if grid.Squares[nhbY][nhbX].Val >= highLevel {
+				highDir = dir
+				highLevel = grid.Squares[nhbY][nhbX].Val
+			}
+		}
+
+		// act
+		if end {
+			// end: skip to next river, out of the lake if it has just been filled
+			var next *River
+			if endLake != -1 && !drained[endLake] && outlets[endLake][0] != -1 {
+				drained[endLake] = true
+				oy, ox := outlets[endLake][0], outlets[endLake][1]
+				if grid.Squares[oy][ox].Feature != FEATURE_RIVER {
+					grid.Squares[oy][ox].Feature = FEATURE_RIVER
+					next = &River{
+						y:         []int{oy},
+						x:         []int{ox},
+						pathStack: []int{0},
+						Level:     grid.Squares[oy][ox].Val,
+					}
+					outOf[next] = endLake
+				}
+			}
+			if next == nil {
+				next, err = NewRiver(grid, world.rng)
+				if err != nil {
+					return err
+				}
+			}
+			rivers = append(rivers, next)
+			riverSurface += river.Len()
+			world.report(STAGE_RIVERS, riverSurface, world.NbLand*grid.RiverPct/100)
+		} else if highDir == -1 {
+			// go back
+			if river.Len() > 1 {
+				grid.Squares[river.Y()][river.X()].Feature = FEATURE_NONE
+				oldY, oldX := river.Y(), river.X()
+				river.GoBack()
+				river.Level -= grid.Squares[oldY][oldX].Val - grid.Squares[river.Y()][river.X()].Val
+			} else if river.Level >= 0 {
+				river.Level--
+			} else {
+				next, err := NewRiver(grid, world.rng)
+				if err != nil {
+					return err
+				}
+				rivers = append(rivers, next)
+			}
+		} else {
+			// move forward
+			nhbY, nhbX := grid.Inside(river.Y()+DIR_NEXT[highDir][0], river.X()+DIR_NEXT[highDir][1])
+			grid.Squares[nhbY][nhbX].Feature = FEATURE_RIVER
+			river.Level += grid.Squares[nhbY][nhbX].Val - grid.Squares[river.Y()][river.X()].Val
+			river.Move(nhbY, nhbX)
+		}
+	}
+	world.logf("%v rivers: %v", len(rivers), riverSurface)
+	world.Rivers = rivers
+	return nil
+}
+
+// AddCities places NbCities cities on land, each drawn with a weight of its
+// CitySuitability and of its distance to the cities already there, so the
+// first ones, which become the capitals, get the best spots; the better the
+// spot, the more people a city starts with, then it grows for CityGrowth turns
+func (world *World) AddCities(ctx context.Context) error {
+	grid := world.Grid
+	suitability := make([][]float64, grid.Height)
+	for y := range suitability {
+		suitability[y] = make([]float64, grid.Width)
+		for x := range suitability[y] {
+			suitability[y][x] = grid.CitySuitability(y, x)
+			grid.Squares[y][x].CityIndex = -1
+		}
+	}
+	isCity := func(y, x int) bool {
+		return grid.Squares[y][x].Feature == FEATURE_CITY
+	}
+	spacing := grid.citySpacing()
+	world.Cities = nil
+	// suitability of each city's spot when it was founded
+	var spots []float64
+	for len(world.Cities) < grid.NbCities {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// no closer than spacing, and less likely up to three times as far
+		dist := distances(grid, isCity)
+		weights := make([]float64, grid.Surface())
+		var total float64
+		for y := range grid.Squares {
+			for x := range grid.Squares[y] {
+				w := suitability[y][x]
+				if d := dist[y][x]; d != -1 && w > 0 {
+					if d <= spacing {
+						w = 0
+					} else if d < 3*spacing {
+						w *= float64(d) / float64(3*spacing)
+					}
+				}
+				weights[y*grid.Width+x] = w
+				total += w
+			}
+		}
+		if total == 0 {
+			if spacing == 0 {
+				// too little land, the world makes do with fewer cities
+				world.logf("cities: no room left for city %v of %v", len(world.Cities)+1, grid.NbCities)
+				break
+			}
+			spacing /= 2
+			world.logf("cities: spacing down to %v", spacing)
+			continue
+		}
+		pick, r := -1, world.rng.Float64()*total
+		for i, w := range weights {
+			if w > 0 {
+				pick = i
+				if r < w {
+					break
+				}
+				r -= w
+			}
+		}
+		y, x := pick/grid.Width, pick%grid.Width
+
+		city := NewCity(y, x)
+		city.Population = int(suitability[y][x]*CITY_POP_PER_SUITABILITY*(.5+world.rng.Float64())) + 1
+		if len(world.Cities) < grid.NbCountries {
+			city.Tier = CITY_TIER_CAPITAL
+			city.Population *= CITY_CAPITAL_FACTOR
+		}
+		city.setTier()
+		spots = append(spots, suitability[y][x])
+		grid.Squares[y][x].Feature = FEATURE_CITY
+		grid.Squares[y][x].CityIndex = len(world.Cities)
+		world.Cities = append(world.Cities, city)
+		world.spreadCity(len(world.Cities)-1, suitability, false)
+		for i := range city.Y {
+			suitability[city.Y[i]][city.X[i]] = 0
+		}
+		world.report(STAGE_CITIES, len(world.Cities), grid.NbCities+grid.CityGrowth)
+	}
+	if err := world.growCities(ctx, suitability, spots); err != nil {
+		return err
+	}
+	counts := make([]int, len(CITY_TIER_NAMES))
+	for _, city := range world.Cities {
+		counts[city.Tier]++
+	}
+	world.logf("%v cities: %v hamlets, %v towns, %v cities, %v capitals", len(world.Cities),
+		counts[CITY_TIER_HAMLET], counts[CITY_TIER_TOWN], counts[CITY_TIER_CITY], counts[CITY_TIER_CAPITAL])
+	return nil
+}
+
+// AddCountries grows NbCountries countries from their capitals, each square
+// going to the country that reaches it at the lowest CountryCosts, so borders
+// follow ridges and rivers where they can, then traces the borders
+func (world *World) AddCountries(ctx context.Context) error {
+	grid := world.Grid
+	cities := world.Cities
+
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			grid.Squares[y][x].CountryIndex = -1
+		}
+	}
+	cg := NewCountryGroup(grid)
+	for _, city := range cities {
+		if city.Tier != CITY_TIER_CAPITAL {
+			continue
+		}
+		cg.AddCountry(NewCountry(city, cg, HSVtoRGBA(cg.CountryCount()*240/grid.NbCountries, .5, .5)))
+		for j := range city.Y {
+			grid.Squares[city.Y[j]][city.X[j]].CountryIndex = cg.CountryCount() - 1
+		}
+	}
+	// every country spreads from its capital, a square going to the one
+	// reaching it at the lowest cost
+	costs := grid.CountryCosts
+	cost := make([][]float64, grid.Height)
+	owner := make([][]int, grid.Height)
+	for y := range cost {
+		cost[y] = make([]float64, grid.Width)
+		owner[y] = make([]int, grid.Width)
+		for x := range cost[y] {
+			cost[y][x] = -1
+		}
+	}
+	queue := &squareQueue{}
+	spread := func(y, x int) {
+		for _, dir := range DIR_NEXT {
+			nhbY, nhbX, ok := grid.Neighbour(y, x, dir)
+			nhb := grid.Squares[nhbY][nhbX]
+			if !ok || nhb.IsWater() || nhb.CountryIndex != -1 {
+				continue
+			}
+			c := cost[y][x] + costs.Distance
+			if nhb.Terrain == TERRAIN_MOUNTAIN {
+				c += costs.Mountain
+			}
+			if nhb.Feature == FEATURE_RIVER {
+				c += costs.River
+			}
+			if cost[nhbY][nhbX] == -1 || c < cost[nhbY][nhbX] {
+				cost[nhbY][nhbX] = c
+				owner[nhbY][nhbX] = grid.Squares[y][x].CountryIndex
+				heap.Push(queue, squareItem{nhbY, nhbX, c})
+			}
+		}
+	}
+	for ic := 0; ic < cg.CountryCount(); ic++ {
+		capital := cg.Get(ic).Cities[0]
+		for j := range capital.Y {
+			cost[capital.Y[j]][capital.X[j]] = 0
+			spread(capital.Y[j], capital.X[j])
+		}
+	}
+	lastPct := -1
+	for queue.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := heap.Pop(queue).(squareItem)
+		st := grid.Squares[s.y][s.x]
+		if st.CountryIndex != -1 || s.priority > cost[s.y][s.x] {
+			continue
+		}
+		ic := owner[s.y][s.x]
+		country := cg.Get(ic)
+		if st.CityIndex != -1 {
+			// the whole city at once
+			city := cities[st.CityIndex]
+			country.TakeCity(city)
+			for j := range city.Y {
+				grid.Squares[city.Y[j]][city.X[j]].CountryIndex = ic
+				cost[city.Y[j]][city.X[j]] = s.priority
+			}
+			for j := range city.Y {
+				spread(city.Y[j], city.X[j])
+			}
+		} else {
+			country.Take(s.y, s.x)
+			st.CountryIndex = ic
+			spread(s.y, s.x)
+		}
+		if pct := 100 * cg.Surface() / world.NbLand; pct != lastPct {
+			if pct/10 != lastPct/10 {
+				world.logf("%v countries: %v%%", cg.CountryCount(), pct)
+			}
+			world.report(STAGE_COUNTRIES, pct, 100)
+			lastPct = pct
+		}
+	}
+	for ic := 0; ic < cg.CountryCount(); ic++ {
+		cg.Get(ic).SharpenBorder()
+	}
+	for i := 0; i < cg.CountryCount(); i++ {
+		country := cg.Get(i)
+		for j := range country.BorderY {
+			y, x := country.BorderY[j], country.BorderX[j]
+			trace := true
+			for _, dir := range DIR_NEXT {
+				yo, xo := grid.Inside(y+dir[0], x+dir[1])
+				if grid.Squares[yo][xo].IsWater() || grid.Squares[yo][xo].Feature == FEATURE_RIVER {
+					trace = false
+					break
+				}
+				if grid.Squares[yo][xo].CountryIndex != i && grid.Squares[yo][xo].Feature == FEATURE_COUNTRY_BORDER {
+					trace = false
+					break
+				}
+			}
+			if trace {
+				grid.Squares[y][x].Feature = FEATURE_COUNTRY_BORDER
+			}
+		}
+	}
+	world.Countries = cg
+	return nil
+}
+
+// AddMapBorders frames the sides of the map that are not connected,
+// the top and bottom rows being the poles
+func (world *World) AddMapBorders(ctx context.Context) error {
+	grid := world.Grid
+	if !grid.ConnectY {
+		for x := 0; x < grid.Width; x++ {
+			for _, y := range []int{0, grid.Height - 1} {
+				grid.Squares[y][x].Terrain = TERRAIN_MAP_BORDER
+				grid.Squares[y][x].Biome = BIOME_ICE
+			}
+		}
+	}
+	if !grid.ConnectX {
+		for y := 0; y < grid.Height; y++ {
+			grid.Squares[y][0].Terrain = TERRAIN_MAP_BORDER
+			grid.Squares[y][grid.Width-1].Terrain = TERRAIN_MAP_BORDER
+			grid.Squares[y][0].Biome = BIOME_NONE
+			grid.Squares[y][grid.Width-1].Biome = BIOME_NONE
+			if y%grid.Magic() < grid.Magic()/2 {
+				grid.Squares[y][0].SetRGBA(150, 150, 150, 255)
+				grid.Squares[y][grid.Width-1].SetRGBA(45, 45, 45, 255)
+			} else {
+				grid.Squares[y][0].SetRGBA(45, 45, 45, 255)
+				grid.Squares[y][grid.Width-1].SetRGBA(150, 150, 150, 255)
+			}
+		}
+	}
+	return nil
+}
+
+// Colorize paints squares according to their terrain
+func (world *World) Colorize(ctx context.Context) error {
+	grid := world.Grid
+	for y := range grid.Squares {
+		for x := range grid.Squares[y] {
+			st := grid.Squares[y][x]
+			switch v := uint8(st.Val); st.Terrain {
+			case TERRAIN_LAND:
+				switch st.Biome {
+				case BIOME_NONE, BIOME_FOREST:
+					st.SetRGBA(v/2, v, 0, 255)
+				case BIOME_ICE:
+					// glaciers
+					c := BIOME_COLORS[BIOME_ICE]
+					st.SetRGBA(c.R, c.G, c.B, 255)
+				default:
+					r, g, b := shade(BIOME_COLORS[st.Biome], v)
+					st.SetRGBA(r, g, b, 255)
+				}
+			case TERRAIN_MOUNTAIN:
+				if st.Biome == BIOME_ICE {
+					// snow caps
+					c := BIOME_COLORS[BIOME_ICE]
+					st.SetRGBA(c.R, c.G, c.B, 255)
+				} else {
+					st.SetRGBA(uint8(int(v)*224/255), uint8(int(v)*228/255), uint8(int(v)*170/255), 255)
+				}
+			case TERRAIN_SEA:
+				if st.IsFrozen() {
+					st.SetRGBA(ICE_SHELF_COLOR.R, ICE_SHELF_COLOR.G, ICE_SHELF_COLOR.B, 255)
+				} else {
+					r, g, b := seaColor(st)
+					st.SetRGBA(r, g, b, 255)
+				}
+			case TERRAIN_LAKE:
+				if st.IsFrozen() {
+					st.SetRGBA(ICE_SHELF_COLOR.R, ICE_SHELF_COLOR.G, ICE_SHELF_COLOR.B, 255)
+				} else {
+					st.SetRGBA(30, uint8(170-int(v)/3), uint8(230-int(v)/4), 255)
+				}
+			case TERRAIN_MAP_BORDER:
+				if st.IsFrozen() {
+					st.SetRGBA(POLE_COLOR.R, POLE_COLOR.G, POLE_COLOR.B, 255)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (world *World) Decorate(ctx context.Context) error {
+	if err := world.Grid.DecorateFeatures(ctx, world.rng, world.Cities); err != nil {
+		return err
+	}
+	world.drawSeaLanes()
+	return nil
+}
@@ -0,0 +1,248 @@
+package lgc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// testConfig is a map small enough to generate in a test
+func testConfig(seed int64, terrain string) *Config {
+	cfg := NewConfig(64, 32)
+	cfg.Seed = seed
+	cfg.Terrain = TERRAIN_GENERATORS[terrain]
+	return cfg
+}
+
+func testWorld(t *testing.T, cfg *Config) *World {
+	t.Helper()
+	world, err := Generate(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("seed %v, %v terrain: %v", cfg.Seed, TerrainGeneratorName(cfg.Terrain), err)
+	}
+	return world
+}
+
+func testPNG(t *testing.T, world *World) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := PrintPNG(world.Grid, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSameSeedSameMap(t *testing.T) {
+	for _, tc := range []struct {
+		terrain string
+		seed    int64
+		setup   func(cfg *Config)
+	}{
+		{"particles", 1, nil},
+		{"quick", 2, nil},
+		{"fbm", 3, func(cfg *Config) { cfg.ConnectX = true }},
+		{"diamond-square", 4, func(cfg *Config) { cfg.LandPct = 40 }},
+		{"plates", 5, func(cfg *Config) { cfg.RiverMode = RIVER_MODE_FLOW }},
+		{"tectonics", 6, func(cfg *Config) { cfg.Erosion, cfg.CityGrowth = 500, 3 }},
+	} {
+		t.Run(tc.terrain, func(t *testing.T) {
+			var maps [2][]byte
+			for i := range maps {
+				cfg := testConfig(tc.seed, tc.terrain)
+				if tc.setup != nil {
+					tc.setup(cfg)
+				}
+				maps[i] = testPNG(t, testWorld(t, cfg))
+			}
+			if !bytes.Equal(maps[0], maps[1]) {
+				t.Errorf("seed %v gave two different maps", tc.seed)
+			}
+
+			cfg := testConfig(tc.seed+100, tc.terrain)
+			if tc.setup != nil {
+				tc.setup(cfg)
+			}
+			if bytes.Equal(maps[0], testPNG(t, testWorld(t, cfg))) {
+				t.Errorf("seeds %v and %v gave the same map", tc.seed, tc.seed+100)
+			}
+		})
+	}
+}
+
+// in both river modes, the water filling a lake flows on out of it
+func TestRiversLeaveLakes(t *testing.T) {
+	// a valley going down from a mountain to the sea, with a lake halfway
+	// and high ground on each side
+	valley := []int{10, 100, 120, 130, 50, 50, 50, 140, 150, 160, 0}
+	for _, tc := range []struct {
+		name string
+		mode int
+	}{
+		{"walk", RIVER_MODE_WALK},
+		{"flow", RIVER_MODE_FLOW},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := NewConfig(len(valley), 3)
+			cfg.Seed, cfg.RiverMode, cfg.RiverPct = 1, tc.mode, 50
+			world := &World{Config: cfg, Grid: NewGrid(cfg), rng: cfg.NewRand()}
+			for y, row := range world.Grid.Squares {
+				for x := range row {
+					st := NewSquareTerrain(cfg, 10)
+					switch {
+					case x == len(valley)-1:
+						st.Terrain, st.Val = TERRAIN_SEA, 0
+					case y != 1 || x == 0:
+						st.Terrain = TERRAIN_LAND
+					case x == 1:
+						st.Terrain, st.Val = TERRAIN_MOUNTAIN, valley[x]
+					case valley[x] == 50:
+						st.Terrain, st.Val = TERRAIN_LAKE, valley[x]
+					default:
+						st.Terrain, st.Val = TERRAIN_LAND, valley[x]
+					}
+					if st.Terrain == TERRAIN_LAND || st.Terrain == TERRAIN_MOUNTAIN {
+						world.NbLand++
+					}
+					row[x] = st
+				}
+			}
+			if err := world.AddRivers(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			for x := 7; x < len(valley)-1; x++ {
+				if world.Grid.Squares[1][x].Feature != FEATURE_RIVER {
+					t.Errorf("no river below the lake at x=%v", x)
+				}
+			}
+		})
+	}
+}
+
+// every map Validate accepts can be generated, down to the smallest sizes and
+// whatever the share of land
+func TestSmallMaps(t *testing.T) {
+	sizes := [][2]int{{3, 3}, {3, 40}, {40, 3}, {5, 40}, {40, 5}, {17, 9}}
+	for _, terrain := range TerrainGeneratorNames() {
+		for _, size := range sizes {
+			for _, wrap := range []struct{ y, x bool }{{false, false}, {false, true}, {true, true}} {
+				for _, landPct := range []int{0, 1, 50, 99} {
+					for seed := int64(1); seed <= 3; seed++ {
+						cfg := NewConfig(size[0], size[1])
+						cfg.Seed, cfg.Terrain = seed, TERRAIN_GENERATORS[terrain]
+						cfg.ConnectY, cfg.ConnectX = wrap.y, wrap.x
+						cfg.LandPct = landPct
+						if err := cfg.Validate(); err != nil {
+							t.Fatalf("%vx%v: %v", size[0], size[1], err)
+						}
+						if _, err := Generate(context.Background(), cfg); err != nil {
+							t.Errorf("%v terrain, %vx%v, wrap %v, %v%% land, seed %v: %v", terrain, size[0], size[1], wrap, landPct, seed, err)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// the sea level leaves LandPct of the map above water, give or take
+// a tolerance, whatever the generator
+func TestLandPct(t *testing.T) {
+	const tolerance = 2
+	for _, terrain := range TerrainGeneratorNames() {
+		for _, landPct := range []int{1, 10, 30, 50, 70, 90, 99} {
+			for seed := int64(1); seed <= 3; seed++ {
+				cfg := testConfig(seed, terrain)
+				cfg.LandPct = landPct
+				rng := cfg.NewRand()
+				squares, err := cfg.Terrain.GenerateTerrain(context.Background(), cfg, rng)
+				if err != nil {
+					t.Fatal(err)
+				}
+				world := NewWorld(cfg, rng, squares)
+				for _, run := range []func(context.Context) error{world.DeleteIsolated, world.SetSeaLevel, world.SplitLandAndSea} {
+					if err := run(context.Background()); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if pct := world.NbLand * 100 / cfg.Surface(); Abs(pct-landPct) > tolerance {
+					t.Errorf("%v terrain, seed %v: %v%% land, expected %v%%", terrain, seed, pct, landPct)
+				}
+			}
+		}
+	}
+}
+
+// cities and roads are on land and ports and sea lanes on the open sea, all
+// off the sides of the map that are not connected
+func TestNetworksOnTheMap(t *testing.T) {
+	for _, wrap := range []struct{ y, x bool }{{false, false}, {false, true}, {true, true}} {
+		for seed := int64(1); seed <= 3; seed++ {
+			cfg := testConfig(seed, "quick")
+			cfg.ConnectY, cfg.ConnectX = wrap.y, wrap.x
+			cfg.NbCities = 20
+			world := testWorld(t, cfg)
+			grid := world.Grid
+			check := func(what string, y, x int, ok bool) {
+				t.Helper()
+				if !ok || grid.OnBorder(y, x) {
+					t.Errorf("wrap %v, seed %v: %v on terrain %v at %v,%v", wrap, seed, what, grid.Squares[y][x].Terrain, y, x)
+				}
+			}
+			for _, city := range world.Cities {
+				for i := range city.Y {
+					check("city", city.Y[i], city.X[i], grid.Squares[city.Y[i]][city.X[i]].Terrain == TERRAIN_LAND)
+				}
+			}
+			for _, road := range world.Roads {
+				for i := range road.Y {
+					st := grid.Squares[road.Y[i]][road.X[i]]
+					check("road", road.Y[i], road.X[i], st.Terrain == TERRAIN_LAND || st.Terrain == TERRAIN_MOUNTAIN)
+				}
+			}
+			for _, port := range world.Ports {
+				st := grid.Squares[port.Y][port.X]
+				check("port", port.Y, port.X, st.IsNavigable() && st.IsShallow())
+			}
+			for _, lane := range world.SeaLanes {
+				for i := range lane.Y {
+					check("sea lane", lane.Y[i], lane.X[i], grid.Squares[lane.Y[i]][lane.X[i]].IsNavigable())
+				}
+			}
+			t.Logf("wrap %v, seed %v: %v cities, %v roads, %v ports, %v lanes", wrap, seed, len(world.Cities), len(world.Roads), len(world.Ports), len(world.SeaLanes))
+		}
+	}
+}
+
+// every land square joined to a capital belongs to a country, and no water
+func TestCountriesCoverLand(t *testing.T) {
+	for _, wrap := range []struct{ y, x bool }{{false, false}, {false, true}, {true, true}} {
+		for seed := int64(1); seed <= 3; seed++ {
+			cfg := testConfig(seed, "fbm")
+			cfg.ConnectY, cfg.ConnectX = wrap.y, wrap.x
+			world := testWorld(t, cfg)
+			grid := world.Grid
+			land, _ := components(grid, func(y, x int) bool {
+				return grid.Squares[y][x].Terrain == TERRAIN_LAND || grid.Squares[y][x].Terrain == TERRAIN_MOUNTAIN
+			})
+			reached := make(map[int]bool)
+			for _, city := range world.Cities {
+				if city.Tier == CITY_TIER_CAPITAL {
+					reached[land[city.CenterY][city.CenterX]] = true
+				}
+			}
+			for y := range grid.Squares {
+				for x, st := range grid.Squares[y] {
+					if reached[land[y][x]] && st.CountryIndex == -1 {
+						t.Errorf("wrap %v, seed %v: land at %v,%v in no country", wrap, seed, y, x)
+					}
+					if st.IsWater() && st.CountryIndex != -1 {
+						t.Errorf("wrap %v, seed %v: water at %v,%v in country %v", wrap, seed, y, x, st.CountryIndex)
+					}
+				}
+			}
+			if len(reached) == 0 {
+				t.Errorf("wrap %v, seed %v: no capital", wrap, seed)
+			}
+		}
+	}
+}
@@ -3,22 +3,191 @@
 package main
 
 import (
-	"math/rand"
-	"time"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/ribacq/LaGrueCendree/lgc"
 )
 
 // make your choice here
-const (
-	GRID_WIDTH  int  = 400
-	GRID_HEIGHT int  = 200
-	CONNECT_Y   bool = false
-	CONNECT_X   bool = false
+var (
+	width        = flag.Int("width", 400, "map width, in squares")
+	height       = flag.Int("height", 200, "map height, in squares")
+	seed         = flag.Int64("seed", 0, "random seed, picked from the clock if not set")
+	wrap         = flag.String("wrap", "none", "edges connected to the opposite side: none, x, y or xy")
+	riverPct     = flag.Int("rivers", 8, "percentage of land covered by rivers")
+	riverMode    = flag.String("river-mode", "walk", "rivers walk down from mountains, or flow where water gathers: walk or flow")
+	landPct      = flag.Int("land", 0, "percentage of the map covered by land, left to the terrain if 0")
+	erosion      = flag.Int("erosion", 0, "erosion droplets run down the land, none if 0")
+	erosionPct   = flag.Int("erosion-strength", 50, "erosion strength, in percent")
+	nbCities     = flag.Int("cities", 0, "number of cities, derived from the map size if 0")
+	nbCountries  = flag.Int("countries", 0, "number of countries, derived from the map size if 0")
+	cityGrowth   = flag.Int("city-growth", 0, "turns of city growth, none if 0")
+	frames       = flag.Int("frames", 0, "terrain simulation frames, derived from the map size if 0")
+	spawnPower   = flag.Int("spawn", 0, "terrain spawn power, derived from the map size if 0")
+	terrain      = flag.String("terrain", "particles", "terrain generator: "+strings.Join(lgc.TerrainGeneratorNames(), ", "))
+	squareWidth  = flag.Int("square-width", 8, "width of a square, in pixels")
+	squareHeight = flag.Int("square-height", 8, "height of a square, in pixels")
+	format       = flag.String("format", "png", "output format: png, ppm, svg, json, gob or geojson")
+	projection   = flag.String("projection", "grid", "geojson coordinates: grid or lonlat")
+	load         = flag.String("load", "", "render a world saved as json or gob instead of generating one")
+	output       = flag.String("o", "-", "output file, - for stdout")
+	timeout      = flag.Duration("timeout", 0, "give up after this long, no limit if 0")
+	progress     = flag.Bool("progress", false, "print stage progress on stderr")
 )
 
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	flag.Usage()
+	os.Exit(2)
+}
+
 func main() {
-	rand.Seed(time.Now().UnixNano())
-	terrain := GenerateTerrain()
-	grid := AddFeaturesToTerrain(terrain)
-	grid.DecorateFeatures()
-	PrintPNG(grid)
+	flag.Parse()
+	if flag.NArg() > 0 {
+		fail(fmt.Errorf("unexpected arguments %v", flag.Args()))
+	}
+
+	cfg := lgc.NewConfig(*width, *height)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			cfg.Seed = *seed
+		}
+	})
+	switch *wrap {
+	case "none":
+	case "x":
+		cfg.ConnectX = true
+	case "y":
+		cfg.ConnectY = true
+	case "xy":
+		cfg.ConnectY, cfg.ConnectX = true, true
+	default:
+		fail(fmt.Errorf("unknown wrap mode %q", *wrap))
+	}
+	cfg.RiverPct = *riverPct
+	switch *riverMode {
+	case "walk":
+		cfg.RiverMode = lgc.RIVER_MODE_WALK
+	case "flow":
+		cfg.RiverMode = lgc.RIVER_MODE_FLOW
+	default:
+		fail(fmt.Errorf("unknown river mode %q", *riverMode))
+	}
+	cfg.LandPct = *landPct
+	cfg.Erosion, cfg.ErosionPct = *erosion, *erosionPct
+	if *nbCities != 0 {
+		cfg.NbCities = *nbCities
+	}
+	if *nbCountries != 0 {
+		cfg.NbCountries = *nbCountries
+	} else if cfg.NbCountries > cfg.NbCities {
+		// the derived count cannot outnumber the cities asked for
+		cfg.NbCountries = cfg.NbCities
+	}
+	cfg.CityGrowth = *cityGrowth
+	if *frames != 0 {
+		cfg.Frames = *frames
+	}
+	if *spawnPower != 0 {
+		cfg.SpawnPower = *spawnPower
+	}
+	gen, err := lgc.NewTerrainGenerator(*terrain)
+	if err != nil {
+		fail(err)
+	}
+	cfg.Terrain = gen
+	cfg.SquareWidth, cfg.SquareHeight = *squareWidth, *squareHeight
+	if err := cfg.Validate(); err != nil {
+		fail(err)
+	}
+
+	var render func(*lgc.World, io.Writer) error
+	switch *format {
+	case "png":
+		render = func(world *lgc.World, w io.Writer) error { return lgc.PrintPNG(world.Grid, w) }
+	case "ppm":
+		render = func(world *lgc.World, w io.Writer) error { return lgc.PrintPPM(world.Grid, w) }
+	case "svg":
+		render = lgc.PrintSVG
+	case "json":
+		render = lgc.SaveJSON
+	case "gob":
+		render = lgc.SaveGob
+	case "geojson":
+		switch *projection {
+		case "grid":
+			render = func(world *lgc.World, w io.Writer) error { return lgc.PrintGeoJSON(world, w, lgc.GridProjection) }
+		case "lonlat":
+			render = func(world *lgc.World, w io.Writer) error {
+				return lgc.PrintGeoJSON(world, w, lgc.LonLatProjection(world.Config))
+			}
+		default:
+			fail(fmt.Errorf("unknown projection %q", *projection))
+		}
+	default:
+		fail(fmt.Errorf("unknown output format %q", *format))
+	}
+
+	logger := log.New(os.Stderr, "", 0)
+	cfg.Logger = logger
+	if *progress {
+		cfg.Progress = func(stage, percent int) {
+			logger.Printf("%v: %v%%", lgc.STAGE_NAMES[stage], percent)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	var world *lgc.World
+	if *load != "" {
+		f, err := os.Open(*load)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		world, err = lgc.Load(f)
+		f.Close()
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("seed %v, loaded from %v", world.Seed, *load)
+	} else {
+		logger.Printf("seed %v", cfg.Seed)
+		var err error
+		world, err = lgc.Generate(ctx, cfg)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	// the output file is only created once there is a world to write in it
+	out := os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		out = f
+	}
+	err = render(world, out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if *output != "-" {
+			os.Remove(*output)
+		}
+		logger.Fatal(err)
+	}
 }